@@ -0,0 +1,114 @@
+// Command breachfilter builds the breached password filter read from
+// BREACHED_PASSWORDS_FILE. The input has one entry per line, either a plain
+// password or a SHA-1 hash in the Have I Been Pwned "HASH:COUNT" format.
+package main
+
+import (
+	"api/src/passwordpolicy"
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"flag"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	input := flag.String("in", "", "password or SHA-1 hash list, one per line")
+	output := flag.String("out", "breached.bloom", "filter file to write")
+	rate := flag.Float64("rate", 0.001, "false positive rate")
+	hashed := flag.Bool("hashed", false, "treat every line as a SHA-1 hash")
+	flag.Parse()
+
+	if *input == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	count, err := countLines(*input)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	filter := passwordpolicy.NewBloomFilter(count, *rate)
+
+	file, err := os.Open(*input)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		if sum, ok := parseHash(line); ok || *hashed {
+			if !ok {
+				log.Fatalf("invalid SHA-1 hash %q", line)
+			}
+			filter.AddHash(sum)
+			continue
+		}
+
+		filter.Add(line)
+	}
+	if err = scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := os.Create(*output)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if _, err = filter.WriteTo(out); err != nil {
+		log.Fatal(err)
+	}
+
+	if err = out.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("wrote %d entries to %s", count, *output)
+}
+
+// parseHash reads a hex SHA-1, optionally followed by ":COUNT".
+func parseHash(line string) ([sha1.Size]byte, bool) {
+	var sum [sha1.Size]byte
+
+	if i := strings.IndexByte(line, ':'); i >= 0 {
+		line = line[:i]
+	}
+
+	if len(line) != hex.EncodedLen(sha1.Size) {
+		return sum, false
+	}
+
+	if _, err := hex.Decode(sum[:], []byte(line)); err != nil {
+		return sum, false
+	}
+
+	return sum, true
+}
+
+func countLines(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	count := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if scanner.Text() != "" {
+			count++
+		}
+	}
+
+	return count, scanner.Err()
+}
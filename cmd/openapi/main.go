@@ -0,0 +1,24 @@
+// Command openapi writes the OpenAPI document of the API to stdout. It fails
+// when a route lacks its spec, so CI runs it to catch undocumented routes
+// before the server refuses to start.
+package main
+
+import (
+	"api/src/router/routes"
+	"encoding/json"
+	"log"
+	"os"
+)
+
+func main() {
+	document, err := routes.Document()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(document); err != nil {
+		log.Fatal(err)
+	}
+}
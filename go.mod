@@ -2,12 +2,13 @@ module api
 
 go 1.17
 
-require github.com/gorilla/mux v1.8.0
-
 require (
-	github.com/badoux/checkmail v1.2.1 // indirect
-	github.com/dgrijalva/jwt-go v3.2.0+incompatible // indirect
-	github.com/go-sql-driver/mysql v1.6.0 // indirect
-	github.com/joho/godotenv v1.4.0 // indirect
-	golang.org/x/crypto v0.0.0-20211215153901-e495a2d5b3d3 // indirect
+	github.com/badoux/checkmail v1.2.1
+	github.com/dgrijalva/jwt-go v3.2.0+incompatible
+	github.com/go-sql-driver/mysql v1.6.0
+	github.com/gorilla/mux v1.8.0
+	github.com/joho/godotenv v1.4.0
+	golang.org/x/crypto v0.0.0-20211215153901-e495a2d5b3d3
 )
+
+require golang.org/x/sys v0.0.0-20210615035016-665e8c7367d1 // indirect
@@ -1,17 +1,148 @@
 package main
 
 import (
+	"api/src/clientip"
 	"api/src/config"
+	"api/src/db"
+	"api/src/health"
+	"api/src/idempotency"
+	"api/src/jobs"
+	"api/src/lockout"
+	"api/src/logger"
+	"api/src/mailer"
+	"api/src/metrics"
+	"api/src/oidc"
+	"api/src/passwordpolicy"
+	"api/src/ratelimit"
 	"api/src/router"
+	"api/src/security"
+	"api/src/storage"
+	"api/src/tracing"
+	"api/src/version"
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
 	config.Load()
-	r := router.GenerateRouter()
+	if err := logger.Configure(); err != nil {
+		log.Fatal(err)
+	}
 
-	fmt.Printf("Listening on port %d", config.Port)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", config.Port), r))
+	if err := security.Configure(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := tracing.Configure(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := passwordpolicy.Configure(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := mailer.Configure(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := storage.Configure(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := clientip.Configure(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := lockout.Configure(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := ratelimit.Configure(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := idempotency.Configure(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := oidc.Configure(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+
+	go jobs.PurgeDeletedUsers(config.PurgeInterval)
+	go jobs.RemoveExpiredExports(config.PurgeInterval)
+	go jobs.RemoveExpiredIdempotencyKeys(config.PurgeInterval)
+	go jobs.RemoveExpiredSessions(config.PurgeInterval)
+
+	if config.AdminPort != 0 {
+		go serveAdmin(config.AdminPort)
+	}
+
+	health.Register("database", db.Ping)
+	health.Register("schema", db.CheckSchema)
+	health.Register("ratelimit", func(ctx context.Context) error { return ratelimit.Ping() })
+
+	r, err := router.GenerateRouter()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	server := &http.Server{Addr: fmt.Sprintf(":%d", config.Port), Handler: r}
+	go shutdownOnSignal(server)
+
+	logger.Default().Info("listening", "port", config.Port, "version", version.Version, "commit", version.Commit)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+
+	<-shutdownDone
+}
+
+// shutdownDone is closed once the server drained and the remaining spans
+// were exported.
+var shutdownDone = make(chan struct{})
+
+// shutdownOnSignal stops the server gracefully on SIGINT or SIGTERM: it fails
+// readiness first, then stops accepting connections and waits for the
+// requests in flight.
+func shutdownOnSignal(server *http.Server) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	received := <-signals
+
+	logger.Default().Info("shutting down", "signal", received.String(), "delay", config.ShutdownDelay.String())
+	health.ShuttingDown()
+	time.Sleep(config.ShutdownDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Default().Error("shutting down server", "error", err)
+	}
+
+	if err := tracing.Shutdown(ctx); err != nil {
+		logger.Default().Error("exporting remaining spans", "error", err)
+	}
+
+	logger.Default().Info("shut down")
+	close(shutdownDone)
+}
+
+// serveAdmin serves operational endpoints, such as metrics, on a port apart
+// from the API.
+func serveAdmin(port int) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+
+	logger.Default().Info("admin listening", "port", port)
+	if err := http.ListenAndServe(fmt.Sprintf(":%d", port), mux); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -2,6 +2,14 @@ CREATE DATABSE IF NOT EXISTS diegobook;
 
 USE diegobook;
 
+DROP TABLE IF EXISTS idempotency_keys;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS personal_access_tokens;
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS login_attempts;
+DROP TABLE IF EXISTS data_exports;
+DROP TABLE IF EXISTS password_resets;
 DROP TABLE IF EXISTS posts;
 DROP TABLE IF EXISTS followers;
 DROP TABLE IF EXISTS users;
@@ -12,6 +20,14 @@ CREATE TABLE users(
   nickname varchar(255) not null unique,
   email varchar(255) not null unique,
   password varchar(255) not null unique,
+  verified_at timestamp null default null,
+  token_version int not null default 0,
+  deleted_at timestamp null default null,
+  is_admin boolean not null default false,
+  totp_secret varchar(64) null default null,
+  totp_enabled boolean not null default false,
+  totp_last_step bigint not null default 0,
+  version int not null default 1,
   created_at timestamp default current_timestamp
 ) ENGINE=INNODB;
 
@@ -40,6 +56,119 @@ CREATE TABLE posts(
   ON DELETE CASCADE,
 
   likes int default 0,
+  version int not null default 1,
+  created_at timestamp default current_timestamp
+) ENGINE=INNODB;
+
+CREATE TABLE password_resets(
+  id int auto_increment primary key,
+
+  user_id int not null,
+  FOREIGN KEY (user_id)
+  REFERENCES users(id)
+  ON DELETE CASCADE,
+
+  token_hash char(64) not null unique,
+  expires_at datetime not null,
+  used_at timestamp null default null,
+  created_at timestamp default current_timestamp
+) ENGINE=INNODB;
+
+CREATE TABLE data_exports(
+  id int auto_increment primary key,
+
+  user_id int not null,
+  FOREIGN KEY (user_id)
+  REFERENCES users(id)
+  ON DELETE CASCADE,
+
+  status varchar(20) not null default 'pending',
+  include_html boolean not null default false,
+  blob_key varchar(255) null default null,
+  error varchar(255) null default null,
+  created_at timestamp default current_timestamp,
+  completed_at datetime null default null,
+  expires_at datetime null default null
+) ENGINE=INNODB;
+
+CREATE TABLE login_attempts(
+  attempt_key varchar(255) primary key,
+  failures int not null default 0,
+  last_failure datetime not null,
+  locked_until datetime null default null
+) ENGINE=INNODB;
+
+CREATE TABLE recovery_codes(
+  id int auto_increment primary key,
+
+  user_id int not null,
+  FOREIGN KEY (user_id)
+  REFERENCES users(id)
+  ON DELETE CASCADE,
+
+  code_hash char(64) not null,
+  used_at timestamp null default null,
+  unique (user_id, code_hash)
+) ENGINE=INNODB;
+
+CREATE TABLE user_identities(
+  id int auto_increment primary key,
+
+  user_id int not null,
+  FOREIGN KEY (user_id)
+  REFERENCES users(id)
+  ON DELETE CASCADE,
+
+  provider varchar(50) not null,
+  subject varchar(255) not null,
+  email varchar(255) not null,
+  created_at timestamp default current_timestamp,
+  unique (provider, subject)
+) ENGINE=INNODB;
+
+CREATE TABLE personal_access_tokens(
+  id int auto_increment primary key,
+
+  user_id int not null,
+  FOREIGN KEY (user_id)
+  REFERENCES users(id)
+  ON DELETE CASCADE,
+
+  name varchar(100) not null,
+  prefix varchar(16) not null,
+  token_hash char(64) not null unique,
+  scopes varchar(255) not null,
+  last_used_at datetime null default null,
+  expires_at datetime null default null,
+  revoked_at datetime null default null,
+  created_at timestamp default current_timestamp
+) ENGINE=INNODB;
+
+CREATE TABLE sessions(
+  id int auto_increment primary key,
+
+  user_id int not null,
+  FOREIGN KEY (user_id)
+  REFERENCES users(id)
+  ON DELETE CASCADE,
+
+  user_agent varchar(512) not null,
+  ip varchar(45) not null,
+  created_at timestamp default current_timestamp,
+  last_seen_at datetime not null,
+  expires_at datetime not null,
+  revoked_at datetime null default null,
+  INDEX (expires_at)
+) ENGINE=INNODB;
+
+CREATE TABLE idempotency_keys(
+  key_hash char(64) primary key,
+  request_hash char(64) not null,
+  status int null default null,
+  headers text null default null,
+  body mediumblob null default null,
+  locked_until datetime not null,
+  expires_at datetime not null,
   created_at timestamp default current_timestamp
 ) ENGINE=INNODB;
 
@@ -0,0 +1,161 @@
+// Package apperrors describes failures the way clients see them: a kind,
+// which sets the HTTP status, a stable code, a message safe to show, and
+// the details of each invalid field. The underlying cause is kept for logs.
+package apperrors
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindInvalid
+	KindUnauthenticated
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindPreconditionFailed
+	KindUnprocessable
+	KindTooLarge
+	KindUnsupportedMediaType
+	KindTooManyRequests
+	KindUnavailable
+	KindNotAcceptable
+)
+
+var statuses = map[Kind]int{
+	KindInternal:             http.StatusInternalServerError,
+	KindInvalid:              http.StatusBadRequest,
+	KindUnauthenticated:      http.StatusUnauthorized,
+	KindForbidden:            http.StatusForbidden,
+	KindNotFound:             http.StatusNotFound,
+	KindConflict:             http.StatusConflict,
+	KindPreconditionFailed:   http.StatusPreconditionFailed,
+	KindUnprocessable:        http.StatusUnprocessableEntity,
+	KindTooLarge:             http.StatusRequestEntityTooLarge,
+	KindUnsupportedMediaType: http.StatusUnsupportedMediaType,
+	KindTooManyRequests:      http.StatusTooManyRequests,
+	KindUnavailable:          http.StatusServiceUnavailable,
+	KindNotAcceptable:        http.StatusNotAcceptable,
+}
+
+// Status returns the HTTP status answered for the kind.
+func (kind Kind) Status() int {
+	return statuses[kind]
+}
+
+// KindForStatus is the kind answered with status, for errors that only came
+// with a status.
+func KindForStatus(status int) Kind {
+	for kind, kindStatus := range statuses {
+		if kindStatus == status {
+			return kind
+		}
+	}
+
+	if status >= 500 {
+		return KindInternal
+	}
+	return KindInvalid
+}
+
+// FieldError explains what is wrong with one field of the request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type Error struct {
+	Kind Kind
+	// Code identifies the error for clients, e.g. "already_exists". It never
+	// changes once published.
+	Code    string
+	Message string
+	Fields  []FieldError
+	// Err is the cause, which is logged but never sent to clients.
+	Err error
+}
+
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// Wrap describes err, keeping it as the cause.
+func Wrap(err error, kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message, Err: err}
+}
+
+// WithFields returns a copy of the error with field details added.
+func (err *Error) WithFields(fields ...FieldError) *Error {
+	copied := *err
+	copied.Fields = append(append([]FieldError(nil), err.Fields...), fields...)
+	return &copied
+}
+
+func (err *Error) Error() string {
+	if err.Err != nil {
+		return err.Message + ": " + err.Err.Error()
+	}
+	return err.Message
+}
+
+func (err *Error) Unwrap() error {
+	return err.Err
+}
+
+// From describes any error for a client. Application errors are kept and
+// database errors translated. Other errors get the kind of status; the
+// message of a server error is never sent since it may hold internals.
+func From(err error, status int) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	if dbErr := fromMySQL(err); dbErr != nil {
+		return dbErr
+	}
+
+	kind := KindForStatus(status)
+	if kind == KindInternal || kind == KindUnavailable {
+		return Wrap(err, kind, codeFor(kind), strings.ToLower(http.StatusText(kind.Status())))
+	}
+
+	return Wrap(err, kind, codeFor(kind), err.Error())
+}
+
+func codeFor(kind Kind) string {
+	switch kind {
+	case KindInvalid:
+		return "bad_request"
+	case KindUnauthenticated:
+		return "unauthenticated"
+	case KindForbidden:
+		return "forbidden"
+	case KindNotFound:
+		return "not_found"
+	case KindConflict:
+		return "conflict"
+	case KindPreconditionFailed:
+		return "precondition_failed"
+	case KindUnprocessable:
+		return "unprocessable"
+	case KindTooLarge:
+		return "too_large"
+	case KindUnsupportedMediaType:
+		return "unsupported_media_type"
+	case KindTooManyRequests:
+		return "too_many_requests"
+	case KindUnavailable:
+		return "unavailable"
+	case KindNotAcceptable:
+		return "not_acceptable"
+	}
+
+	return "internal"
+}
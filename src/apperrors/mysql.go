@@ -0,0 +1,62 @@
+package apperrors
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL error numbers we translate.
+const (
+	mysqlDuplicateEntry   = 1062
+	mysqlRowIsReferenced  = 1451
+	mysqlNoReferencedRow  = 1452
+	mysqlLockWaitTimeout  = 1205
+	mysqlDeadlock         = 1213
+	mysqlRowIsReferenced2 = 1217
+	mysqlNoReferencedRow2 = 1216
+)
+
+var (
+	// Duplicate entry 'bob' for key 'users.nickname', or 'nickname' before
+	// MySQL 8.
+	duplicateKey = regexp.MustCompile(`for key '(?:[^'.]+\.)?([^']+)'`)
+	// ... FOREIGN KEY (`author_id`) REFERENCES `users` (`id`) ...
+	foreignKey = regexp.MustCompile("FOREIGN KEY \\(`([^`]+)`\\)")
+)
+
+// fromMySQL translates the driver errors caused by the request itself, or
+// returns nil for any other error.
+func fromMySQL(err error) *Error {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return nil
+	}
+
+	switch mysqlErr.Number {
+	case mysqlDuplicateEntry:
+		appErr := Wrap(err, KindConflict, "already_exists", "a resource with the same values already exists")
+		if match := duplicateKey.FindStringSubmatch(mysqlErr.Message); match != nil && match[1] != "PRIMARY" {
+			field := strings.TrimSuffix(match[1], "_UNIQUE")
+			appErr = appErr.WithFields(FieldError{Field: field, Code: "taken", Message: field + " is already in use"})
+		}
+		return appErr
+
+	case mysqlNoReferencedRow, mysqlNoReferencedRow2:
+		appErr := Wrap(err, KindUnprocessable, "invalid_reference", "the request refers to a resource that doesn't exist")
+		if match := foreignKey.FindStringSubmatch(mysqlErr.Message); match != nil {
+			appErr = appErr.WithFields(FieldError{Field: match[1], Code: "not_found", Message: match[1] + " doesn't exist"})
+		}
+		return appErr
+
+	case mysqlRowIsReferenced, mysqlRowIsReferenced2:
+		return Wrap(err, KindConflict, "still_referenced", "the resource is still in use by others")
+
+	case mysqlDeadlock, mysqlLockWaitTimeout:
+		return Wrap(err, KindUnavailable, "retry_later", "the request conflicted with another one, try again")
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package authentication
+
+import (
+	"api/src/config"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const twoFactorChallengePurpose = "two_factor_challenge"
+
+// CreateChallengeToken signs the short-lived token returned by the first login
+// step of a user with two-factor authentication. It proves the password was
+// checked and can only be exchanged at /login/2fa.
+func CreateChallengeToken(userID uint64, tokenVersion uint64) (string, error) {
+	claims := jwt.MapClaims{}
+	claims["user_id"] = userID
+	claims["token_version"] = tokenVersion
+	return createPurposeToken(twoFactorChallengePurpose, claims, config.TwoFactorChallengeTTL)
+}
+
+func ParseChallengeToken(tokenString string) (uint64, uint64, error) {
+	claims, err := parsePurposeToken(twoFactorChallengePurpose, tokenString)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	userID, err := claimUint(claims, "user_id")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	tokenVersion, err := claimUint(claims, "token_version")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return userID, tokenVersion, nil
+}
@@ -0,0 +1,18 @@
+package authentication
+
+import "context"
+
+type contextKey string
+
+const userIDKey contextKey = "user_id"
+
+// WithUserID stores the authenticated user in the request context, so the
+// credentials, a JWT or a personal access token, are only read once.
+func WithUserID(ctx context.Context, userID uint64) context.Context {
+	return context.WithValue(ctx, userIDKey, userID)
+}
+
+func userIDFromContext(ctx context.Context) (uint64, bool) {
+	userID, ok := ctx.Value(userIDKey).(uint64)
+	return userID, ok
+}
@@ -0,0 +1,51 @@
+package authentication
+
+import (
+	"api/src/security"
+	"net/http"
+	"strings"
+)
+
+const PersonalAccessTokenPrefix = "pat_"
+
+const (
+	ScopeUsersRead    = "users:read"
+	ScopePostsRead    = "posts:read"
+	ScopePostsWrite   = "posts:write"
+	ScopeFollowsRead  = "follows:read"
+	ScopeFollowsWrite = "follows:write"
+)
+
+var Scopes = []string{ScopeUsersRead, ScopePostsRead, ScopePostsWrite, ScopeFollowsRead, ScopeFollowsWrite}
+
+func ValidScope(scope string) bool {
+	for _, known := range Scopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
+
+// GeneratePersonalAccessToken returns a new token and its public prefix, which
+// identifies the token in listings without revealing it.
+func GeneratePersonalAccessToken() (string, string, error) {
+	secret, err := security.GenerateToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	token := PersonalAccessTokenPrefix + secret
+	return token, token[:len(PersonalAccessTokenPrefix)+8], nil
+}
+
+// ExtractPersonalAccessToken returns the bearer token if it is a personal
+// access token, or an empty string.
+func ExtractPersonalAccessToken(r *http.Request) string {
+	token := extractToken(r)
+	if strings.HasPrefix(token, PersonalAccessTokenPrefix) {
+		return token
+	}
+
+	return ""
+}
@@ -0,0 +1,55 @@
+package authentication
+
+import (
+	"api/src/config"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// createPurposeToken signs claims with a key derived for purpose only, so the
+// token can never be used as an access token or for another purpose.
+func createPurposeToken(purpose string, claims jwt.MapClaims, ttl time.Duration) (string, error) {
+	claims["purpose"] = purpose
+	claims["exp"] = time.Now().Add(ttl).Unix()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(purposeKey(purpose))
+}
+
+func parsePurposeToken(purpose, tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+		return purposeKey(purpose), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid || claims["purpose"] != purpose {
+		return nil, errors.New("Invalid token")
+	}
+
+	return claims, nil
+}
+
+func claimUint(claims jwt.MapClaims, name string) (uint64, error) {
+	if _, ok := claims[name]; !ok {
+		return 0, nil
+	}
+
+	return strconv.ParseUint(fmt.Sprintf("%.0f", claims[name]), 10, 64)
+}
+
+func purposeKey(purpose string) []byte {
+	mac := hmac.New(sha256.New, config.SecretKey)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
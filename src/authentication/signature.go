@@ -0,0 +1,18 @@
+package authentication
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// Sign returns an HMAC of message with a key derived for purpose.
+func Sign(purpose, message string) string {
+	mac := hmac.New(sha256.New, purposeKey(purpose))
+	mac.Write([]byte(message))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func VerifySignature(purpose, message, signature string) bool {
+	return hmac.Equal([]byte(Sign(purpose, message)), []byte(signature))
+}
@@ -12,11 +12,18 @@ import (
 	"github.com/dgrijalva/jwt-go"
 )
 
-func CreateToken(userID uint64) (string, error) {
+// TokenLifetime is how long access tokens, and their sessions, are valid.
+const TokenLifetime = 6 * time.Hour
+
+// CreateToken issues an access token bound to a session, so that revoking
+// the session revokes the token.
+func CreateToken(userID uint64, tokenVersion uint64, sessionID uint64) (string, error) {
 	permissions := jwt.MapClaims{}
 	permissions["authorized"] = true
 	permissions["user_id"] = userID
-	permissions["exp"] = time.Now().Add(time.Hour * 6).Unix()
+	permissions["token_version"] = tokenVersion
+	permissions["session_id"] = sessionID
+	permissions["exp"] = time.Now().Add(TokenLifetime).Unix()
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, permissions)
 	return token.SignedString([]byte(config.SecretKey))
 }
@@ -36,6 +43,10 @@ func ValidateToken(r *http.Request) error {
 }
 
 func ExtractUserId(r *http.Request) (uint64, error) {
+	if userID, ok := userIDFromContext(r.Context()); ok {
+		return userID, nil
+	}
+
 	tokenString := extractToken(r)
 	token, err := jwt.Parse(tokenString, returnSecretKey)
 	if err != nil {
@@ -54,6 +65,46 @@ func ExtractUserId(r *http.Request) (uint64, error) {
 	return 0, errors.New("Invalid token")
 }
 
+// ExtractTokenVersion returns the token version the token was issued with.
+// Tokens issued before versions existed are treated as version 0.
+func ExtractTokenVersion(r *http.Request) (uint64, error) {
+	tokenString := extractToken(r)
+	token, err := jwt.Parse(tokenString, returnSecretKey)
+	if err != nil {
+		return 0, err
+	}
+
+	if permissions, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+		if _, ok := permissions["token_version"]; !ok {
+			return 0, nil
+		}
+
+		return strconv.ParseUint(fmt.Sprintf("%.0f", permissions["token_version"]), 10, 64)
+	}
+
+	return 0, errors.New("Invalid token")
+}
+
+// ExtractSessionID returns the session the token is bound to, or 0 for
+// tokens issued before sessions existed.
+func ExtractSessionID(r *http.Request) (uint64, error) {
+	tokenString := extractToken(r)
+	token, err := jwt.Parse(tokenString, returnSecretKey)
+	if err != nil {
+		return 0, err
+	}
+
+	if permissions, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+		if _, ok := permissions["session_id"]; !ok {
+			return 0, nil
+		}
+
+		return strconv.ParseUint(fmt.Sprintf("%.0f", permissions["session_id"]), 10, 64)
+	}
+
+	return 0, errors.New("Invalid token")
+}
+
 func extractToken(r *http.Request) string {
 	token := r.Header.Get("Authorization")
 	if len(strings.Split(token, " ")) == 2 {
@@ -0,0 +1,32 @@
+package authentication
+
+import (
+	"api/src/config"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const emailVerificationPurpose = "email_verification"
+
+// CreateVerificationToken signs a token proving ownership of email.
+func CreateVerificationToken(userID uint64, email string) (string, error) {
+	claims := jwt.MapClaims{}
+	claims["user_id"] = userID
+	claims["email"] = email
+	return createPurposeToken(emailVerificationPurpose, claims, config.VerificationTokenTTL)
+}
+
+func ParseVerificationToken(tokenString string) (uint64, string, error) {
+	claims, err := parsePurposeToken(emailVerificationPurpose, tokenString)
+	if err != nil {
+		return 0, "", err
+	}
+
+	userID, err := claimUint(claims, "user_id")
+	if err != nil {
+		return 0, "", err
+	}
+
+	email, _ := claims["email"].(string)
+	return userID, email, nil
+}
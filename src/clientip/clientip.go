@@ -0,0 +1,104 @@
+package clientip
+
+import (
+	"api/src/config"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+var trustedProxies []*net.IPNet
+
+// Configure parses config.TrustedProxies.
+func Configure() error {
+	var proxies []*net.IPNet
+	for _, proxy := range config.TrustedProxies {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		proxies = append(proxies, network)
+	}
+
+	trustedProxies = proxies
+	return nil
+}
+
+// FromRequest returns the IP address of the client. X-Forwarded-For is only
+// honored when the API runs behind a proxy, and the request comes from one of
+// the trusted proxies; anyone else could put anything in it. Proxies append
+// to the header, so its entries are read from the right, where the client
+// can't forge them: the first one that isn't a trusted proxy is the client.
+func FromRequest(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	host = Normalize(host)
+
+	if !config.TrustProxy || !trusted(host) {
+		return host
+	}
+
+	var forwarded []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		forwarded = append(forwarded, strings.Split(header, ",")...)
+	}
+
+	// client is the last address vouched for, where the chain stops when
+	// every entry left of it is a trusted proxy or isn't an address.
+	client := host
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		address := strings.TrimSpace(forwarded[i])
+		if address == "" {
+			continue
+		}
+
+		ip := net.ParseIP(address)
+		if ip == nil {
+			break
+		}
+
+		client = ip.String()
+		if !trusted(client) {
+			break
+		}
+	}
+
+	return client
+}
+
+// Normalize gives an IP address its canonical form, e.g. "::ffff:10.0.0.1"
+// becomes "10.0.0.1", so every spelling of an address keys the same limits.
+// Anything else is returned as is.
+func Normalize(address string) string {
+	if ip := net.ParseIP(address); ip != nil {
+		return ip.String()
+	}
+
+	return address
+}
+
+func trusted(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
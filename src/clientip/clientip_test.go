@@ -0,0 +1,83 @@
+package clientip
+
+import (
+	"api/src/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFromRequest(t *testing.T) {
+	config.TrustedProxies = []string{"10.0.0.0/8", "192.168.1.1"}
+	if err := Configure(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		config.TrustProxy = false
+		config.TrustedProxies = nil
+		trustedProxies = nil
+	}()
+
+	tests := []struct {
+		name       string
+		trustProxy bool
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{name: "direct client", trustProxy: true, remoteAddr: "203.0.113.7:4242", want: "203.0.113.7"},
+		{name: "proxies not trusted", remoteAddr: "10.0.0.1:4242", forwarded: []string{"203.0.113.7"}, want: "10.0.0.1"},
+		{
+			name:       "forged header from a direct client",
+			trustProxy: true,
+			remoteAddr: "203.0.113.7:4242",
+			forwarded:  []string{"198.51.100.1"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "trusted proxy chain",
+			trustProxy: true,
+			remoteAddr: "10.0.0.1:4242",
+			forwarded:  []string{"203.0.113.7, 192.168.1.1", "10.0.0.2"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "forged entries left of the client",
+			trustProxy: true,
+			remoteAddr: "10.0.0.1:4242",
+			forwarded:  []string{"198.51.100.1, 203.0.113.7"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "garbage entries are skipped",
+			trustProxy: true,
+			remoteAddr: "10.0.0.1:4242",
+			forwarded:  []string{"not an address, 10.0.0.2"},
+			want:       "10.0.0.2",
+		},
+		{
+			name:       "entries are normalized",
+			trustProxy: true,
+			remoteAddr: "10.0.0.1:4242",
+			forwarded:  []string{"::ffff:203.0.113.7"},
+			want:       "203.0.113.7",
+		},
+		{name: "remote address is normalized", remoteAddr: "[::ffff:203.0.113.7]:4242", want: "203.0.113.7"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config.TrustProxy = test.trustProxy
+
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.RemoteAddr = test.remoteAddr
+			for _, header := range test.forwarded {
+				request.Header.Add("X-Forwarded-For", header)
+			}
+
+			if got := FromRequest(request); got != test.want {
+				t.Errorf("FromRequest() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -5,14 +5,117 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
 var (
 	StringDbConnection = ""
 	Port               = 0
 	SecretKey          []byte
+	// Debug adds internal details, such as panic stacks, to error responses.
+	Debug = false
+	// MaxBodySize is the largest request body accepted, in bytes, by routes
+	// that don't set their own limit.
+	MaxBodySize = 1 << 20
+	// UnversionedSunset, when set, is announced as the date the unversioned
+	// aliases of the /v1 and /v2 routes stop being served.
+	UnversionedSunset time.Time
+
+	AppURL               = ""
+	VerificationTokenTTL = 24 * time.Hour
+	UnverifiedCanLogin   = true
+	UnverifiedCanPost    = false
+	PasswordResetTTL     = time.Hour
+
+	DeletionGracePeriod = 30 * 24 * time.Hour
+	PurgeInterval       = time.Hour
+
+	StorageDriver   = "local"
+	StorageDir      = ""
+	ExportLinkTTL   = 24 * time.Hour
+	ExportRetention = 7 * 24 * time.Hour
+
+	TrustProxy = false
+	// TrustedProxies lists the addresses or CIDR ranges of the proxies in
+	// front of the API. X-Forwarded-For is only read on requests from them,
+	// and they are skipped when reading it.
+	TrustedProxies []string
+
+	LoginAttemptStore  = "memory"
+	LoginMaxFailures   = 5
+	LoginIPMaxFailures = 50
+	LoginLockout       = 15 * time.Minute
+	LoginBackoffBase   = time.Second
+	LoginBackoffMax    = time.Minute
+	LoginFailureWindow = time.Hour
+
+	TwoFactorIssuer       = "Diegobook"
+	TwoFactorChallengeTTL = 5 * time.Minute
+
+	OIDCProviders []OIDCProvider
+	OIDCFlowTTL   = 10 * time.Minute
+
+	PasswordHasher = "argon2id"
+	// Argon2Memory is in KiB. The parameters are checked by
+	// security.Configure, before they are narrowed to the types of argon2.
+	Argon2Memory      = 64 * 1024
+	Argon2Iterations  = 3
+	Argon2Parallelism = 2
+	BcryptCost        = 10
+
+	PasswordMinLength     = 10
+	PasswordMaxLength     = 128
+	PasswordMinEntropy    = 40.0
+	BreachedPasswordsFile = ""
+
+	IdempotencyStore = "memory"
+	IdempotencyTTL   = 24 * time.Hour
+	// IdempotencyLockTimeout is how long a key stays claimed by a request
+	// that didn't finish, e.g. because the instance crashed, before a retry
+	// can take it over.
+	IdempotencyLockTimeout = time.Minute
+
+	RateLimitStore = "memory"
+	RedisAddr      = "localhost:6379"
+	RedisPassword  = ""
+	RedisDB        = 0
+
+	LogLevel  = "info"
+	LogFormat = "json"
+
+	AdminPort = 9090
+
+	// ShutdownDelay is how long the server keeps serving, while failing
+	// readiness, before it stops accepting connections, so orchestrators stop
+	// sending traffic first. ShutdownTimeout bounds the wait for the requests
+	// in flight.
+	ShutdownDelay   = 5 * time.Second
+	ShutdownTimeout = 30 * time.Second
+
+	ServiceName        = "api"
+	TracingExporter    = "none"
+	TracingSampleRatio = 1.0
+	OTLPEndpoint       = "http://localhost:4318"
+
+	MailerDriver = "memory"
+	MailFrom     = ""
+	MailDropDir  = ""
+	SMTPHost     = ""
+	SMTPPort     = 587
+	SMTPUser     = ""
+	SMTPPassword = ""
 )
 
 func Load() {
@@ -34,4 +137,164 @@ func Load() {
 	)
 
 	SecretKey = []byte(os.Getenv("SECRET_KEY"))
+	Debug = getBool("DEBUG", Debug)
+	MaxBodySize = getInt("MAX_BODY_SIZE", MaxBodySize)
+
+	AppURL = getString("APP_URL", fmt.Sprintf("http://localhost:%d", Port))
+	VerificationTokenTTL = getDuration("VERIFICATION_TOKEN_TTL", VerificationTokenTTL)
+	UnverifiedCanLogin = getBool("UNVERIFIED_CAN_LOGIN", UnverifiedCanLogin)
+	UnverifiedCanPost = getBool("UNVERIFIED_CAN_POST", UnverifiedCanPost)
+	PasswordResetTTL = getDuration("PASSWORD_RESET_TTL", PasswordResetTTL)
+
+	DeletionGracePeriod = getDuration("DELETION_GRACE_PERIOD", DeletionGracePeriod)
+	PurgeInterval = getDuration("PURGE_INTERVAL", PurgeInterval)
+
+	StorageDriver = getString("STORAGE", StorageDriver)
+	StorageDir = getString("STORAGE_DIR", "tmp/storage")
+	ExportLinkTTL = getDuration("EXPORT_LINK_TTL", ExportLinkTTL)
+	ExportRetention = getDuration("EXPORT_RETENTION", ExportRetention)
+
+	TrustProxy = getBool("TRUST_PROXY", TrustProxy)
+	TrustedProxies = getList("TRUSTED_PROXIES")
+	LoginAttemptStore = getString("LOGIN_ATTEMPT_STORE", LoginAttemptStore)
+	LoginMaxFailures = getInt("LOGIN_MAX_FAILURES", LoginMaxFailures)
+	LoginIPMaxFailures = getInt("LOGIN_IP_MAX_FAILURES", LoginIPMaxFailures)
+	LoginLockout = getDuration("LOGIN_LOCKOUT", LoginLockout)
+	LoginBackoffBase = getDuration("LOGIN_BACKOFF_BASE", LoginBackoffBase)
+	LoginBackoffMax = getDuration("LOGIN_BACKOFF_MAX", LoginBackoffMax)
+	LoginFailureWindow = getDuration("LOGIN_FAILURE_WINDOW", LoginFailureWindow)
+
+	TwoFactorIssuer = getString("TWO_FACTOR_ISSUER", TwoFactorIssuer)
+	TwoFactorChallengeTTL = getDuration("TWO_FACTOR_CHALLENGE_TTL", TwoFactorChallengeTTL)
+
+	OIDCProviders = loadOIDCProviders()
+	OIDCFlowTTL = getDuration("OIDC_FLOW_TTL", OIDCFlowTTL)
+
+	PasswordHasher = getString("PASSWORD_HASHER", PasswordHasher)
+	Argon2Memory = getInt("ARGON2_MEMORY", Argon2Memory)
+	Argon2Iterations = getInt("ARGON2_ITERATIONS", Argon2Iterations)
+	Argon2Parallelism = getInt("ARGON2_PARALLELISM", Argon2Parallelism)
+	BcryptCost = getInt("BCRYPT_COST", BcryptCost)
+
+	PasswordMinLength = getInt("PASSWORD_MIN_LENGTH", PasswordMinLength)
+	PasswordMaxLength = getInt("PASSWORD_MAX_LENGTH", PasswordMaxLength)
+	PasswordMinEntropy = getFloat("PASSWORD_MIN_ENTROPY", PasswordMinEntropy)
+	BreachedPasswordsFile = os.Getenv("BREACHED_PASSWORDS_FILE")
+
+	UnversionedSunset = getTime("UNVERSIONED_SUNSET", UnversionedSunset)
+
+	IdempotencyStore = getString("IDEMPOTENCY_STORE", IdempotencyStore)
+	IdempotencyTTL = getDuration("IDEMPOTENCY_TTL", IdempotencyTTL)
+	IdempotencyLockTimeout = getDuration("IDEMPOTENCY_LOCK_TIMEOUT", IdempotencyLockTimeout)
+
+	RateLimitStore = getString("RATE_LIMIT_STORE", RateLimitStore)
+	RedisAddr = getString("REDIS_ADDR", RedisAddr)
+	RedisPassword = os.Getenv("REDIS_PASSWORD")
+	RedisDB = getInt("REDIS_DB", RedisDB)
+
+	LogLevel = getString("LOG_LEVEL", LogLevel)
+	LogFormat = getString("LOG_FORMAT", LogFormat)
+
+	AdminPort = getInt("ADMIN_PORT", AdminPort)
+	ShutdownDelay = getDuration("SHUTDOWN_DELAY", ShutdownDelay)
+	ShutdownTimeout = getDuration("SHUTDOWN_TIMEOUT", ShutdownTimeout)
+
+	ServiceName = getString("SERVICE_NAME", ServiceName)
+	TracingExporter = getString("TRACING_EXPORTER", TracingExporter)
+	TracingSampleRatio = getFloat("TRACING_SAMPLE_RATIO", TracingSampleRatio)
+	OTLPEndpoint = getString("OTLP_ENDPOINT", OTLPEndpoint)
+
+	MailerDriver = getString("MAILER", MailerDriver)
+	MailFrom = getString("MAIL_FROM", "no-reply@localhost")
+	MailDropDir = getString("MAIL_DROP_DIR", "tmp/mail")
+	SMTPHost = os.Getenv("SMTP_HOST")
+	SMTPPort = getInt("SMTP_PORT", SMTPPort)
+	SMTPUser = os.Getenv("SMTP_USER")
+	SMTPPassword = os.Getenv("SMTP_PASSWORD")
+}
+
+// loadOIDCProviders reads the providers named in OIDC_PROVIDERS, e.g.
+// "google,gitlab", each configured by OIDC_<NAME>_* variables.
+func loadOIDCProviders() []OIDCProvider {
+	var providers []OIDCProvider
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		providers = append(providers, OIDCProvider{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  getString(prefix+"REDIRECT_URL", fmt.Sprintf("%s/auth/%s/callback", AppURL, name)),
+			Scopes:       strings.Fields(getString(prefix+"SCOPES", "openid email profile")),
+		})
+	}
+
+	return providers
+}
+
+func getString(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getFloat(key string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getList reads a comma separated list, e.g. "10.0.0.0/8, 192.168.1.1".
+func getList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getTime reads an RFC 3339 timestamp or a date, e.g. "2027-06-30".
+func getTime(key string, fallback time.Time) time.Time {
+	value := os.Getenv(key)
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed
+		}
+	}
+
+	return fallback
 }
@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"api/src/db"
+	"api/src/lockout"
+	"api/src/repositories"
+	"api/src/responses"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+func UnlockUser(w http.ResponseWriter, r *http.Request) {
+	parameters := mux.Vars(r)
+	userId, err := strconv.ParseUint(parameters["userId"], 10, 64)
+	if err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	db, err := db.ConnectContext(r.Context())
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	user, err := repositories.NewUserRepository(db).FindById(userId)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if user.ID == 0 {
+		responses.Err(w, r, http.StatusNotFound, errors.New("user not found"))
+		return
+	}
+
+	if err = lockout.Accounts.Reset(lockout.AccountKey(strings.ToLower(user.Email))); err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	responses.JSON(w, r, http.StatusNoContent, nil)
+}
+
+func UnlockIP(w http.ResponseWriter, r *http.Request) {
+	ip := net.ParseIP(mux.Vars(r)["ip"])
+	if ip == nil {
+		responses.Err(w, r, http.StatusBadRequest, errors.New("invalid ip address"))
+		return
+	}
+
+	if err := lockout.IPs.Reset(lockout.IPKey(ip.String())); err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	responses.JSON(w, r, http.StatusNoContent, nil)
+}
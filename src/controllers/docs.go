@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"api/src/logger"
+	"api/src/openapi"
+	"api/src/responses"
+	"net/http"
+	"strconv"
+)
+
+// OpenAPIDocument serves the OpenAPI document generated at startup.
+func OpenAPIDocument(w http.ResponseWriter, r *http.Request) {
+	responses.JSON(w, r, http.StatusOK, openapi.Current())
+}
+
+// APIDocs serves a page rendering the OpenAPI document for humans.
+func APIDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(openapi.DocsPage)))
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write(openapi.DocsPage); err != nil {
+		logger.FromContext(r.Context()).Error("writing docs page", "error", err)
+	}
+}
@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"api/src/authentication"
+	"api/src/config"
+	"api/src/db"
+	"api/src/export"
+	"api/src/logger"
+	"api/src/models"
+	"api/src/repositories"
+	"api/src/requests"
+	"api/src/responses"
+	"api/src/storage"
+	"api/src/versioning"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const exportDownloadPurpose = "data_export_download"
+
+func RequestExport(w http.ResponseWriter, r *http.Request) {
+	parameters := mux.Vars(r)
+	userId, err := strconv.ParseUint(parameters["userId"], 10, 64)
+	if err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	userIdOnToken, err := authentication.ExtractUserId(r)
+	if err != nil {
+		responses.Err(w, r, http.StatusUnauthorized, err)
+		return
+	}
+
+	if userId != userIdOnToken {
+		responses.Err(w, r, http.StatusForbidden, errors.New("you can only export your own data"))
+		return
+	}
+
+	// The body is optional, the defaults apply without one.
+	var request models.DataExport
+	if err = requests.DecodeJSON(r, &request); err != nil && err != requests.ErrEmptyBody {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	db, err := db.ConnectContext(r.Context())
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	repository := repositories.NewDataExportRepository(db)
+	exportID, err := repository.Create(userId, request.IncludeHTML)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	dataExport, err := repository.FindById(exportID)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	go export.Run(logger.FromContext(r.Context()), exportID)
+
+	w.Header().Set("Location", fmt.Sprintf("%s/users/%d/exports/%d", versioning.Prefix(versioning.FromContext(r.Context())), userId, exportID))
+	responses.JSON(w, r, http.StatusAccepted, dataExport)
+}
+
+func FindExport(w http.ResponseWriter, r *http.Request) {
+	parameters := mux.Vars(r)
+	userId, err := strconv.ParseUint(parameters["userId"], 10, 64)
+	if err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	exportID, err := strconv.ParseUint(parameters["exportId"], 10, 64)
+	if err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	userIdOnToken, err := authentication.ExtractUserId(r)
+	if err != nil {
+		responses.Err(w, r, http.StatusUnauthorized, err)
+		return
+	}
+
+	if userId != userIdOnToken {
+		responses.Err(w, r, http.StatusForbidden, errors.New("you can only see your own exports"))
+		return
+	}
+
+	db, err := db.ConnectContext(r.Context())
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	dataExport, err := repositories.NewDataExportRepository(db).FindById(exportID)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if dataExport.ID == 0 || dataExport.UserID != userId {
+		responses.Err(w, r, http.StatusNotFound, errors.New("export not found"))
+		return
+	}
+
+	if dataExport.Status == models.ExportReady {
+		dataExport.DownloadURL = exportDownloadURL(dataExport.ID)
+	}
+
+	responses.JSON(w, r, http.StatusOK, dataExport)
+}
+
+func DownloadExport(w http.ResponseWriter, r *http.Request) {
+	parameters := mux.Vars(r)
+	exportID, err := strconv.ParseUint(parameters["exportId"], 10, 64)
+	if err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil {
+		responses.Err(w, r, http.StatusForbidden, errors.New("invalid download link"))
+		return
+	}
+
+	signature := r.URL.Query().Get("signature")
+	if !authentication.VerifySignature(exportDownloadPurpose, downloadMessage(exportID, expires), signature) {
+		responses.Err(w, r, http.StatusForbidden, errors.New("invalid download link"))
+		return
+	}
+
+	if time.Now().Unix() > expires {
+		responses.Err(w, r, http.StatusGone, errors.New("the download link has expired"))
+		return
+	}
+
+	db, err := db.ConnectContext(r.Context())
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	dataExport, err := repositories.NewDataExportRepository(db).FindById(exportID)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if dataExport.Status != models.ExportReady {
+		responses.Err(w, r, http.StatusGone, errors.New("the archive is no longer available"))
+		return
+	}
+
+	archive, err := storage.Current().Get(dataExport.BlobKey)
+	if err == storage.ErrNotFound {
+		responses.Err(w, r, http.StatusGone, errors.New("the archive is no longer available"))
+		return
+	}
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer archive.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="export-%d.zip"`, dataExport.ID))
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, archive)
+}
+
+func exportDownloadURL(exportID uint64) string {
+	expires := time.Now().Add(config.ExportLinkTTL).Unix()
+	signature := authentication.Sign(exportDownloadPurpose, downloadMessage(exportID, expires))
+
+	return fmt.Sprintf("%s/exports/%d/download?expires=%d&signature=%s",
+		config.AppURL, exportID, expires, url.QueryEscape(signature),
+	)
+}
+
+func downloadMessage(exportID uint64, expires int64) string {
+	return fmt.Sprintf("%d:%d", exportID, expires)
+}
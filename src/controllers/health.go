@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"api/src/health"
+	"api/src/responses"
+	"api/src/version"
+	"net/http"
+)
+
+// Healthz answers as long as the process can serve requests at all.
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	responses.JSON(w, r, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz answers whether the dependencies of the API are usable, with the
+// outcome and latency of each check. Why a check fails is only logged.
+func Readyz(w http.ResponseWriter, r *http.Request) {
+	report := health.Run(r.Context())
+	if !report.Ready() {
+		responses.JSON(w, r, http.StatusServiceUnavailable, report)
+		return
+	}
+
+	responses.JSON(w, r, http.StatusOK, report)
+}
+
+func Version(w http.ResponseWriter, r *http.Request) {
+	responses.JSON(w, r, http.StatusOK, version.Get())
+}
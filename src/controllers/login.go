@@ -2,32 +2,83 @@ package controllers
 
 import (
 	"api/src/authentication"
+	"api/src/clientip"
+	"api/src/config"
 	"api/src/db"
+	"api/src/lockout"
+	"api/src/logger"
+	"api/src/metrics"
 	"api/src/models"
 	"api/src/repositories"
+	"api/src/requests"
 	"api/src/responses"
 	"api/src/security"
-	"encoding/json"
-	"io/ioutil"
+	"context"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	errInvalidCredentials = errors.New("invalid email or password")
+	errTooManyAttempts    = errors.New("too many failed login attempts, try again later")
+)
+
+// dummyPasswordHash is compared against when the email is unknown, so that
+// the response time doesn't reveal whether an account exists. It is made on
+// first use, with the configured hasher.
+var (
+	dummyPasswordHash     []byte
+	dummyPasswordHashOnce sync.Once
 )
 
 func Login(w http.ResponseWriter, r *http.Request) {
-	requestBody, err := ioutil.ReadAll(r.Body)
+	var user models.User
+	err := requests.DecodeJSON(r, &user)
 	if err != nil {
-		responses.Err(w, http.StatusUnprocessableEntity, err)
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
 	}
 
-	var user models.User
-	if err = json.Unmarshal(requestBody, &user); err != nil {
-		responses.Err(w, http.StatusBadRequest, err)
+	accountKey := lockout.AccountKey(strings.ToLower(strings.TrimSpace(user.Email)))
+	ipKey := lockout.IPKey(clientip.FromRequest(r))
+
+	// The attempt is counted before the password is compared, so parallel
+	// guesses can't all get through the same opening of the backoff.
+	attempts := []struct {
+		guard *lockout.Guard
+		key   string
+	}{{lockout.Accounts, accountKey}, {lockout.IPs, ipKey}}
+	for i, attempt := range attempts {
+		retryAfter, err := attempt.guard.Attempt(attempt.key)
+		if err == nil && retryAfter == 0 {
+			continue
+		}
+
+		for _, reserved := range attempts[:i] {
+			if forgiveErr := reserved.guard.Forgive(reserved.key); forgiveErr != nil {
+				logger.FromContext(r.Context()).Warn("forgiving login attempt", "error", forgiveErr)
+			}
+		}
+
+		if err != nil {
+			responses.Err(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		metrics.LoginFailures.Inc("locked_out")
+		w.Header().Set("Retry-After", fmt.Sprintf("%.0f", math.Ceil(retryAfter.Seconds())))
+		responses.Err(w, r, http.StatusTooManyRequests, errTooManyAttempts)
 		return
 	}
 
-	db, err := db.Connect()
+	db, err := db.ConnectContext(r.Context())
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 	defer db.Close()
@@ -35,22 +86,111 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	repository := repositories.NewUserRepository(db)
 	userFromDb, err := repository.FindByEmail(user.Email)
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
-	if err = security.VerifyPassword(userFromDb.Password, user.Password); err != nil {
-		responses.Err(w, http.StatusUnauthorized, err)
+	valid, err := checkCredentials(repository, userFromDb, user.Password)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
-	token, err := authentication.CreateToken(userFromDb.ID)
-	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+	if !valid {
+		if err = lockout.Accounts.Fail(accountKey); err != nil {
+			responses.Err(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if err = lockout.IPs.Fail(ipKey); err != nil {
+			responses.Err(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		metrics.LoginFailures.Inc("invalid_credentials")
+		responses.Err(w, r, http.StatusUnauthorized, errInvalidCredentials)
+		return
+	}
+
+	if err = lockout.Accounts.Reset(accountKey); err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = lockout.IPs.Forgive(ipKey); err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if security.NeedsRehash(userFromDb.Password) {
+		rehashPassword(r.Context(), repository, userFromDb.ID, user.Password)
+	}
+
+	if !config.UnverifiedCanLogin && userFromDb.VerifiedAt == nil {
+		responses.Err(w, r, http.StatusForbidden, errors.New("you need to verify your email before logging in"))
 		return
 	}
 
 	userID := strconv.FormatUint(userFromDb.ID, 10)
 
-	responses.JSON(w, http.StatusOK, models.AuthenticationData{ID: userID, Token: token})
+	if userFromDb.TOTPEnabled {
+		challengeToken, err := authentication.CreateChallengeToken(userFromDb.ID, userFromDb.TokenVersion)
+		if err != nil {
+			responses.Err(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		responses.JSON(w, r, http.StatusOK, models.AuthenticationData{
+			ID:                userID,
+			TwoFactorRequired: true,
+			ChallengeToken:    challengeToken,
+		})
+		return
+	}
+
+	token, err := startSession(db, r, userFromDb.ID, userFromDb.TokenVersion)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	responses.JSON(w, r, http.StatusOK, models.AuthenticationData{ID: userID, Token: token})
+}
+
+// checkCredentials verifies the password and restores an account pending
+// deletion that is still within its grace period. Unknown accounts, wrong
+// passwords and purged accounts all fail the same way.
+func checkCredentials(repository *repositories.Users, user models.User, password string) (bool, error) {
+	if user.ID == 0 {
+		dummyPasswordHashOnce.Do(func() {
+			dummyPasswordHash, _ = security.Hash("dummy password used to equalize timing")
+		})
+		security.VerifyPassword(string(dummyPasswordHash), password)
+		return false, nil
+	}
+
+	if err := security.VerifyPassword(user.Password, password); err != nil {
+		return false, nil
+	}
+
+	if user.DeletedAt != nil {
+		return repository.Restore(user.ID, config.DeletionGracePeriod)
+	}
+
+	return true, nil
+}
+
+// rehashPassword upgrades a hash made with an outdated algorithm or weaker
+// parameters, now that we know the password. Failing only delays the upgrade
+// to the next login.
+func rehashPassword(ctx context.Context, repository *repositories.Users, userID uint64, password string) {
+	hashedPassword, err := security.Hash(password)
+	if err != nil {
+		logger.FromContext(ctx).Error("rehashing password", "user_id", userID, "error", err)
+		return
+	}
+
+	if err = repository.UpdatePassword(userID, string(hashedPassword)); err != nil {
+		logger.FromContext(ctx).Error("rehashing password", "user_id", userID, "error", err)
+	}
 }
@@ -0,0 +1,286 @@
+package controllers
+
+import (
+	"api/src/authentication"
+	"api/src/config"
+	"api/src/db"
+	"api/src/logger"
+	"api/src/models"
+	"api/src/oidc"
+	"api/src/repositories"
+	"api/src/responses"
+	"api/src/security"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const oidcFlowCookie = "oidc_flow"
+
+var (
+	errUnverifiedIdentity = errors.New("the provider didn't verify your email address")
+	errIdentityGone       = errors.New("the account linked to this identity no longer exists")
+	errUnverifiedAccount  = errors.New("an account with this email exists but never verified it, log in with its password or reset it")
+	nicknameCharacters    = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+)
+
+func OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	provider, ok := oidc.Get(mux.Vars(r)["provider"])
+	if !ok {
+		responses.Err(w, r, http.StatusNotFound, errors.New("unknown identity provider"))
+		return
+	}
+
+	flow, err := oidc.NewFlow(provider.Name(), config.OIDCFlowTTL)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	cookie, err := flow.Encode()
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    cookie,
+		Path:     "/auth/",
+		MaxAge:   int(config.OIDCFlowTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(config.AppURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, provider.AuthCodeURL(flow.State, flow.Nonce, flow.CodeChallenge()), http.StatusFound)
+}
+
+func OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	provider, ok := oidc.Get(mux.Vars(r)["provider"])
+	if !ok {
+		responses.Err(w, r, http.StatusNotFound, errors.New("unknown identity provider"))
+		return
+	}
+
+	query := r.URL.Query()
+	if providerError := query.Get("error"); providerError != "" {
+		responses.Err(w, r, http.StatusUnauthorized, fmt.Errorf("the provider refused the login: %s", providerError))
+		return
+	}
+
+	cookie, err := r.Cookie(oidcFlowCookie)
+	if err != nil {
+		responses.Err(w, r, http.StatusBadRequest, errors.New("the login flow was not started"))
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: oidcFlowCookie, Path: "/auth/", MaxAge: -1})
+
+	flow, err := oidc.DecodeFlow(cookie.Value)
+	if err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if flow.Provider != provider.Name() || subtle.ConstantTimeCompare([]byte(flow.State), []byte(query.Get("state"))) != 1 {
+		responses.Err(w, r, http.StatusBadRequest, errors.New("the login state doesn't match"))
+		return
+	}
+
+	identity, err := provider.Exchange(r.Context(), query.Get("code"), flow.CodeVerifier, flow.Nonce)
+	if err != nil {
+		responses.Err(w, r, http.StatusUnauthorized, err)
+		return
+	}
+
+	db, err := db.ConnectContext(r.Context())
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	userID, err := linkIdentity(r.Context(), repositories.NewIdentityRepository(db), repositories.NewUserRepository(db), identity)
+	if err == errUnverifiedIdentity || err == errIdentityGone || err == errUnverifiedAccount {
+		responses.Err(w, r, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	repository := repositories.NewUserRepository(db)
+	tokenVersion, err := repository.GetTokenVersion(userID)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	_, totpEnabled, err := repository.GetTOTP(userID)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if totpEnabled {
+		challengeToken, err := authentication.CreateChallengeToken(userID, tokenVersion)
+		if err != nil {
+			responses.Err(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		responses.JSON(w, r, http.StatusOK, models.AuthenticationData{
+			ID:                strconv.FormatUint(userID, 10),
+			TwoFactorRequired: true,
+			ChallengeToken:    challengeToken,
+		})
+		return
+	}
+
+	token, err := startSession(db, r, userID, tokenVersion)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	responses.JSON(w, r, http.StatusOK, models.AuthenticationData{ID: strconv.FormatUint(userID, 10), Token: token})
+}
+
+// identityRepository and externalUserRepository are the parts of the
+// repositories linkIdentity uses.
+type identityRepository interface {
+	FindUserID(provider, subject string) (uint64, error)
+	Create(userID uint64, provider, subject, email string) error
+}
+
+type externalUserRepository interface {
+	Create(user models.User) (uint64, error)
+	FindById(id uint64) (models.User, error)
+	FindByEmail(email string) (models.User, error)
+	Restore(ID uint64, gracePeriod time.Duration) (bool, error)
+	Verify(userID uint64, email string) (bool, error)
+}
+
+// linkIdentity returns the user of an external identity. Unknown identities
+// are linked to the account with the same email, only when both the provider
+// and the account verified that email, or get a new account. An unverified
+// account may have been registered by someone else with the victim's email,
+// waiting for the identity to be linked to it.
+func linkIdentity(ctx context.Context, identities identityRepository, users externalUserRepository, identity oidc.Identity) (uint64, error) {
+	userID, err := identities.FindUserID(identity.Provider, identity.Subject)
+	if err != nil {
+		return 0, err
+	}
+
+	if userID != 0 {
+		return userID, restoreIfDeleted(users, userID)
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return 0, errUnverifiedIdentity
+	}
+
+	user, err := users.FindByEmail(identity.Email)
+	if err != nil {
+		return 0, err
+	}
+
+	if user.ID != 0 {
+		if user.VerifiedAt == nil {
+			return 0, errUnverifiedAccount
+		}
+
+		if err = restoreIfDeleted(users, user.ID); err != nil {
+			return 0, err
+		}
+	} else {
+		if user.ID, err = createExternalUser(ctx, users, identity); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = identities.Create(user.ID, identity.Provider, identity.Subject, identity.Email); err != nil {
+		return 0, err
+	}
+
+	return user.ID, nil
+}
+
+func restoreIfDeleted(users externalUserRepository, userID uint64) error {
+	user, err := users.FindById(userID)
+	if err != nil {
+		return err
+	}
+
+	if user.ID != 0 {
+		return nil
+	}
+
+	restored, err := users.Restore(userID, config.DeletionGracePeriod)
+	if err != nil {
+		return err
+	}
+
+	if !restored {
+		return errIdentityGone
+	}
+
+	return nil
+}
+
+// createExternalUser registers an account for an identity whose email the
+// provider verified. It gets an unusable random password until the user sets
+// one through the password reset flow.
+func createExternalUser(ctx context.Context, users externalUserRepository, identity oidc.Identity) (uint64, error) {
+	password, err := security.GenerateToken()
+	if err != nil {
+		return 0, err
+	}
+
+	suffix, err := security.GenerateToken()
+	if err != nil {
+		return 0, err
+	}
+
+	localPart := strings.Split(identity.Email, "@")[0]
+	nickname := nicknameCharacters.ReplaceAllString(localPart, "")
+	if len(nickname) > 40 {
+		nickname = nickname[:40]
+	}
+	nickname += "_" + strings.ToLower(nicknameCharacters.ReplaceAllString(suffix, ""))[:6]
+
+	name := identity.Name
+	if name == "" {
+		name = localPart
+	}
+
+	user := models.User{Name: name, Nickname: nickname, Email: identity.Email, Password: password}
+	// The generated password is random, so the password policy, which
+	// could refuse it for happening to contain part of the email, is
+	// skipped.
+	if err = user.Prepare("external"); err != nil {
+		return 0, err
+	}
+
+	userID, err := users.Create(user)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err = users.Verify(userID, user.Email); err != nil {
+		logger.FromContext(ctx).Error("verifying user created from identity provider",
+			"user_id", userID, "provider", identity.Provider, "error", err)
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"api/src/config"
+	"api/src/models"
+	"api/src/oidc"
+	"api/src/oidc/oidctest"
+	"api/src/security"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type fakeIdentities struct {
+	links map[string]uint64
+}
+
+func (identities *fakeIdentities) FindUserID(provider, subject string) (uint64, error) {
+	return identities.links[provider+"|"+subject], nil
+}
+
+func (identities *fakeIdentities) Create(userID uint64, provider, subject, email string) error {
+	identities.links[provider+"|"+subject] = userID
+	return nil
+}
+
+type fakeUsers struct {
+	users map[uint64]models.User
+}
+
+func (users *fakeUsers) Create(user models.User) (uint64, error) {
+	user.ID = uint64(len(users.users) + 1)
+	users.users[user.ID] = user
+	return user.ID, nil
+}
+
+func (users *fakeUsers) FindById(id uint64) (models.User, error) {
+	return users.users[id], nil
+}
+
+func (users *fakeUsers) FindByEmail(email string) (models.User, error) {
+	for _, user := range users.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return models.User{}, nil
+}
+
+func (users *fakeUsers) Restore(ID uint64, gracePeriod time.Duration) (bool, error) {
+	return false, nil
+}
+
+func (users *fakeUsers) Verify(userID uint64, email string) (bool, error) {
+	user := users.users[userID]
+	now := time.Now()
+	user.VerifiedAt = &now
+	users.users[userID] = user
+	return true, nil
+}
+
+// authorize runs the login flow against the mock provider, from the redirect
+// of OIDCLogin to the checks of the callback, and returns the identity the
+// provider vouched for.
+func authorize(t *testing.T, provider *oidctest.Provider) oidc.Identity {
+	t.Helper()
+
+	request := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/auth/mock/login", nil), map[string]string{"provider": "mock"})
+	recorder := httptest.NewRecorder()
+	OIDCLogin(recorder, request)
+	if recorder.Code != http.StatusFound {
+		t.Fatalf("OIDCLogin answered %d, want %d", recorder.Code, http.StatusFound)
+	}
+
+	client := provider.Server.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	response, err := client.Get(recorder.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	callback, err := url.Parse(response.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cookie string
+	for _, set := range recorder.Result().Cookies() {
+		if set.Name == oidcFlowCookie {
+			cookie = set.Value
+		}
+	}
+
+	flow, err := oidc.DecodeFlow(cookie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flow.State != callback.Query().Get("state") {
+		t.Fatalf("the callback state %q doesn't match the flow", callback.Query().Get("state"))
+	}
+
+	relying, _ := oidc.Get("mock")
+	identity, err := relying.Exchange(context.Background(), callback.Query().Get("code"), flow.CodeVerifier, flow.Nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return identity
+}
+
+func TestOIDCLinkIdentity(t *testing.T) {
+	config.SecretKey = []byte("test-secret-key")
+	config.AppURL = "http://api.test"
+	config.PasswordHasher = "bcrypt"
+	config.BcryptCost = 4
+	if err := security.Configure(); err != nil {
+		t.Fatal(err)
+	}
+
+	provider, err := oidctest.NewProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer provider.Close()
+
+	relying, err := provider.Relying("mock", config.AppURL+"/auth/mock/callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oidc.Register(relying)
+
+	verified := time.Now()
+	tests := []struct {
+		name          string
+		emailVerified bool
+		existing      []models.User
+		linked        bool
+		wantUser      uint64
+		wantErr       error
+	}{
+		{name: "unknown email gets a verified account", emailVerified: true},
+		{
+			name:          "verified account is linked",
+			emailVerified: true,
+			existing:      []models.User{{ID: 1, Email: "mock@example.com", VerifiedAt: &verified}},
+			wantUser:      1,
+		},
+		{
+			name:          "unverified account is refused",
+			emailVerified: true,
+			existing:      []models.User{{ID: 1, Email: "mock@example.com", Password: "attacker's hash"}},
+			wantErr:       errUnverifiedAccount,
+		},
+		{
+			name:          "identity already linked",
+			emailVerified: true,
+			existing:      []models.User{{ID: 1, Email: "other@example.com", VerifiedAt: &verified}},
+			linked:        true,
+			wantUser:      1,
+		},
+		{name: "email unverified by the provider", wantErr: errUnverifiedIdentity},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider.User = oidctest.User{Subject: "subject", Email: "mock@example.com", EmailVerified: test.emailVerified, Name: "Mock User"}
+
+			identities := &fakeIdentities{links: map[string]uint64{}}
+			users := &fakeUsers{users: map[uint64]models.User{}}
+			for _, user := range test.existing {
+				users.users[user.ID] = user
+			}
+			if test.linked {
+				identities.links["mock|subject"] = 1
+			}
+
+			identity := authorize(t, provider)
+			userID, err := linkIdentity(context.Background(), identities, users, identity)
+			if err != test.wantErr {
+				t.Fatalf("linkIdentity error = %v, want %v", err, test.wantErr)
+			}
+
+			if test.wantErr != nil {
+				if len(identities.links) != 0 {
+					t.Errorf("the identity was linked to %v", identities.links)
+				}
+				return
+			}
+
+			if test.wantUser != 0 && userID != test.wantUser {
+				t.Errorf("linked to user %d, want %d", userID, test.wantUser)
+			}
+			if identities.links["mock|subject"] != userID {
+				t.Errorf("identity links = %v, want user %d", identities.links, userID)
+			}
+			if users.users[userID].VerifiedAt == nil {
+				t.Errorf("user %d isn't verified", userID)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"api/src/config"
+	"api/src/db"
+	"api/src/logger"
+	"api/src/mailer"
+	"api/src/models"
+	"api/src/passwordpolicy"
+	"api/src/repositories"
+	"api/src/requests"
+	"api/src/responses"
+	"api/src/security"
+	"api/src/validation"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+var errInvalidResetToken = errors.New("invalid or expired reset token")
+
+func ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var reset models.PasswordReset
+	err := requests.DecodeJSON(r, &reset)
+	if err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = validation.New().Field("email", reset.Email, validation.Required(), validation.Email()).Err(); err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	db, err := db.ConnectContext(r.Context())
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	user, err := repositories.NewUserRepository(db).FindByEmail(reset.Email)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	// The answer is the same whether or not the address is registered, so this
+	// endpoint can't be used to discover accounts.
+	if user.ID != 0 && user.DeletedAt == nil {
+		token, err := security.GenerateToken()
+		if err != nil {
+			responses.Err(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		repository := repositories.NewPasswordResetRepository(db)
+		if err = repository.Create(user.ID, security.HashToken(token), config.PasswordResetTTL); err != nil {
+			responses.Err(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		requestLogger := logger.FromContext(r.Context())
+		go func() {
+			if err := sendPasswordResetEmail(user, token); err != nil {
+				requestLogger.Error("sending password reset email", "user_id", user.ID, "error", err)
+			}
+		}()
+	}
+
+	responses.JSON(w, r, http.StatusAccepted, nil)
+}
+
+func ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var reset models.PasswordReset
+	err := requests.DecodeJSON(r, &reset)
+	if err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	err = validation.New().
+		Field("token", reset.Token, validation.Required()).
+		Field("password", reset.Password, validation.Required()).
+		Err()
+	if err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	db, err := db.ConnectContext(r.Context())
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	tokenHash := security.HashToken(reset.Token)
+	resetRepository := repositories.NewPasswordResetRepository(db)
+	userRepository := repositories.NewUserRepository(db)
+
+	// The new password is checked before using up the token, so a rejected
+	// password can be corrected with the same link.
+	userID, err := resetRepository.FindUser(tokenHash)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if userID == 0 {
+		responses.Err(w, r, http.StatusBadRequest, errInvalidResetToken)
+		return
+	}
+
+	user, err := userRepository.FindById(userID)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	// The account is pending deletion.
+	if user.ID == 0 {
+		responses.Err(w, r, http.StatusBadRequest, errInvalidResetToken)
+		return
+	}
+
+	if err = passwordpolicy.Check(reset.Password, user.Name, user.Nickname, user.Email); err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if userID, err = resetRepository.Consume(tokenHash); err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if userID == 0 {
+		responses.Err(w, r, http.StatusBadRequest, errInvalidResetToken)
+		return
+	}
+
+	hashedPassword, err := security.Hash(reset.Password)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = userRepository.ResetPassword(userID, string(hashedPassword)); err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = resetRepository.ExpireByUser(userID); err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = repositories.NewPersonalAccessTokenRepository(db).RevokeAll(userID); err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	responses.JSON(w, r, http.StatusNoContent, nil)
+}
+
+func sendPasswordResetEmail(user models.User, token string) error {
+	link := fmt.Sprintf("%s/reset-password?token=%s", config.AppURL, url.QueryEscape(token))
+
+	return mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSomeone asked to reset the password of your account. If it was you, open the link below:\n\n%s\n\nThe link can be used once and expires in %s. If you didn't ask for it, you can ignore this email.\n",
+			user.Name, link, config.PasswordResetTTL,
+		),
+	})
+}
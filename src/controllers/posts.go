@@ -2,13 +2,16 @@ package controllers
 
 import (
 	"api/src/authentication"
+	"api/src/config"
 	"api/src/db"
+	"api/src/etag"
+	"api/src/metrics"
 	"api/src/models"
+	"api/src/patch"
 	"api/src/repositories"
+	"api/src/requests"
 	"api/src/responses"
-	"encoding/json"
 	"errors"
-	"io/ioutil"
 	"net/http"
 	"strconv"
 
@@ -18,57 +21,64 @@ import (
 func CreatePost(w http.ResponseWriter, r *http.Request) {
 	userID, err := authentication.ExtractUserId(r)
 	if err != nil {
-		responses.Err(w, http.StatusBadRequest, err)
-		return
-	}
-
-	requestBody, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		responses.Err(w, http.StatusUnprocessableEntity, err)
+		responses.Err(w, r, http.StatusBadRequest, err)
 		return
 	}
 
 	var post models.Post
-	err = json.Unmarshal(requestBody, &post)
-	if err != nil {
-		responses.Err(w, http.StatusUnprocessableEntity, err)
+	if err = requests.DecodeJSON(r, &post); err != nil {
+		responses.Err(w, r, http.StatusUnprocessableEntity, err)
 		return
 	}
 
 	post.AuthorID = userID
 
 	if err = post.Prepare(); err != nil {
-		responses.Err(w, http.StatusUnprocessableEntity, err)
+		responses.Err(w, r, http.StatusUnprocessableEntity, err)
 		return
 	}
 
-	db, err := db.Connect()
+	db, err := db.ConnectContext(r.Context())
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 	defer db.Close()
 
+	if !config.UnverifiedCanPost {
+		author, err := repositories.NewUserRepository(db).FindById(userID)
+		if err != nil {
+			responses.Err(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if author.VerifiedAt == nil {
+			responses.Err(w, r, http.StatusForbidden, errors.New("you need to verify your email before posting"))
+			return
+		}
+	}
+
 	repository := repositories.NewPostRepository(db)
 	post.ID, err = repository.Create(post)
 	if err != nil {
-		responses.Err(w, http.StatusBadRequest, err)
+		responses.Err(w, r, http.StatusBadRequest, err)
 		return
 	}
+	metrics.PostsCreated.Inc()
 
-	responses.JSON(w, http.StatusCreated, post)
+	responses.JSON(w, r, http.StatusCreated, post)
 }
 
 func FindAllPosts(w http.ResponseWriter, r *http.Request) {
 	userID, err := authentication.ExtractUserId(r)
 	if err != nil {
-		responses.Err(w, http.StatusUnauthorized, err)
+		responses.Err(w, r, http.StatusUnauthorized, err)
 		return
 	}
 
-	db, err := db.Connect()
+	db, err := db.ConnectContext(r.Context())
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 	defer db.Close()
@@ -76,24 +86,24 @@ func FindAllPosts(w http.ResponseWriter, r *http.Request) {
 	repository := repositories.NewPostRepository(db)
 	posts, err := repository.Find(userID)
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
-	responses.JSON(w, http.StatusOK, posts)
+	responses.JSON(w, r, http.StatusOK, posts)
 }
 
 func FindPost(w http.ResponseWriter, r *http.Request) {
 	parameters := mux.Vars(r)
 	postID, err := strconv.ParseUint(parameters["postId"], 10, 64)
 	if err != nil {
-		responses.Err(w, http.StatusBadRequest, err)
+		responses.Err(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	db, err := db.Connect()
+	db, err := db.ConnectContext(r.Context())
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 	defer db.Close()
@@ -101,29 +111,41 @@ func FindPost(w http.ResponseWriter, r *http.Request) {
 	repository := repositories.NewPostRepository(db)
 	post, err := repository.FindById(postID)
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if post.ID == 0 {
+		responses.Err(w, r, http.StatusNotFound, errors.New("post not found"))
 		return
 	}
 
-	responses.JSON(w, http.StatusOK, post)
+	etag.Set(w, post.Version)
+	if etag.NotModified(r, post.Version) {
+		responses.JSON(w, r, http.StatusNotModified, nil)
+		return
+	}
+
+	responses.JSON(w, r, http.StatusOK, post)
 }
 
 func UpdatePost(w http.ResponseWriter, r *http.Request) {
 	userID, err := authentication.ExtractUserId(r)
 	if err != nil {
-		responses.Err(w, http.StatusUnauthorized, err)
+		responses.Err(w, r, http.StatusUnauthorized, err)
+		return
 	}
 
 	parameters := mux.Vars(r)
 	postID, err := strconv.ParseUint(parameters["postId"], 10, 64)
 	if err != nil {
-		responses.Err(w, http.StatusBadRequest, err)
+		responses.Err(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	db, err := db.Connect()
+	db, err := db.ConnectContext(r.Context())
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 	defer db.Close()
@@ -131,56 +153,150 @@ func UpdatePost(w http.ResponseWriter, r *http.Request) {
 	repository := repositories.NewPostRepository(db)
 	postSavedOnDb, err := repository.FindById(postID)
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if postSavedOnDb.ID == 0 {
+		responses.Err(w, r, http.StatusNotFound, errors.New("post not found"))
 		return
 	}
 
 	if postSavedOnDb.AuthorID != userID {
-		responses.Err(w, http.StatusForbidden, errors.New("you can't update a post from another user"))
+		responses.Err(w, r, http.StatusForbidden, errors.New("you can't update a post from another user"))
 		return
 	}
 
-	requestBody, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		responses.Err(w, http.StatusUnprocessableEntity, err)
+	if !etag.Matches(r, postSavedOnDb.Version) {
+		responses.Err(w, r, http.StatusPreconditionFailed, etag.ErrPreconditionFailed)
 		return
 	}
 
 	var post models.Post
-	if err = json.Unmarshal(requestBody, &post); err != nil {
-		responses.Err(w, http.StatusBadRequest, err)
+	if err = requests.DecodeJSON(r, &post); err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
 		return
 	}
 
 	if err = post.Prepare(); err != nil {
-		responses.Err(w, http.StatusBadRequest, err)
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	updated, err := repository.Update(postID, post, postSavedOnDb.Version)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !updated {
+		responses.Err(w, r, http.StatusConflict, etag.Stale(r))
+		return
+	}
+
+	etag.Set(w, postSavedOnDb.Version+1)
+	responses.JSON(w, r, http.StatusNoContent, nil)
+}
+
+func PatchPost(w http.ResponseWriter, r *http.Request) {
+	userID, err := authentication.ExtractUserId(r)
+	if err != nil {
+		responses.Err(w, r, http.StatusUnauthorized, err)
+		return
+	}
+
+	parameters := mux.Vars(r)
+	postID, err := strconv.ParseUint(parameters["postId"], 10, 64)
+	if err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	db, err := db.ConnectContext(r.Context())
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	repository := repositories.NewPostRepository(db)
+	postSavedOnDb, err := repository.FindById(postID)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
-	if err = repository.Update(postID, post); err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+	if postSavedOnDb.ID == 0 {
+		responses.Err(w, r, http.StatusNotFound, errors.New("post not found"))
 		return
 	}
 
-	responses.JSON(w, http.StatusNoContent, nil)
+	if postSavedOnDb.AuthorID != userID {
+		responses.Err(w, r, http.StatusForbidden, errors.New("you can't update a post from another user"))
+		return
+	}
+
+	if !etag.Matches(r, postSavedOnDb.Version) {
+		responses.Err(w, r, http.StatusPreconditionFailed, etag.ErrPreconditionFailed)
+		return
+	}
+
+	requestBody, err := requests.ReadBody(r)
+	if err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var post models.Post
+	fields, err := patch.Apply(r.Header.Get("Content-Type"), postSavedOnDb, []string{"title", "content"}, requestBody, &post)
+	if err == patch.ErrUnsupportedContentType {
+		responses.Err(w, r, http.StatusUnsupportedMediaType, err)
+		return
+	}
+	if err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = post.PrepareFields(fields); err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	updated, err := repository.UpdateFields(postID, post, fields, postSavedOnDb.Version)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !updated {
+		responses.Err(w, r, http.StatusConflict, etag.Stale(r))
+		return
+	}
+
+	if len(fields) > 0 {
+		etag.Set(w, postSavedOnDb.Version+1)
+	}
+	responses.JSON(w, r, http.StatusNoContent, nil)
 }
 
 func DeletePost(w http.ResponseWriter, r *http.Request) {
 	userID, err := authentication.ExtractUserId(r)
 	if err != nil {
-		responses.Err(w, http.StatusUnauthorized, err)
+		responses.Err(w, r, http.StatusUnauthorized, err)
+		return
 	}
 
 	parameters := mux.Vars(r)
 	postID, err := strconv.ParseUint(parameters["postId"], 10, 64)
 	if err != nil {
-		responses.Err(w, http.StatusBadRequest, err)
+		responses.Err(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	db, err := db.Connect()
+	db, err := db.ConnectContext(r.Context())
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 	defer db.Close()
@@ -188,34 +304,50 @@ func DeletePost(w http.ResponseWriter, r *http.Request) {
 	repository := repositories.NewPostRepository(db)
 	postSavedOnDb, err := repository.FindById(postID)
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if postSavedOnDb.ID == 0 {
+		responses.Err(w, r, http.StatusNotFound, errors.New("post not found"))
 		return
 	}
 
 	if postSavedOnDb.AuthorID != userID {
-		responses.Err(w, http.StatusForbidden, errors.New("you can't update a post from another user"))
+		responses.Err(w, r, http.StatusForbidden, errors.New("you can't update a post from another user"))
+		return
+	}
+
+	if !etag.Matches(r, postSavedOnDb.Version) {
+		responses.Err(w, r, http.StatusPreconditionFailed, etag.ErrPreconditionFailed)
 		return
 	}
 
-	if err = repository.Delete(postID); err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+	deleted, err := repository.Delete(postID, postSavedOnDb.Version)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !deleted {
+		responses.Err(w, r, http.StatusConflict, etag.Stale(r))
 		return
 	}
 
-	responses.JSON(w, http.StatusNoContent, nil)
+	responses.JSON(w, r, http.StatusNoContent, nil)
 }
 
 func FindPostsByUser(w http.ResponseWriter, r *http.Request) {
 	parameters := mux.Vars(r)
 	userID, err := strconv.ParseUint(parameters["userId"], 10, 64)
 	if err != nil {
-		responses.Err(w, http.StatusBadRequest, err)
+		responses.Err(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	db, err := db.Connect()
+	db, err := db.ConnectContext(r.Context())
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 	defer db.Close()
@@ -223,11 +355,11 @@ func FindPostsByUser(w http.ResponseWriter, r *http.Request) {
 	repository := repositories.NewPostRepository(db)
 	posts, err := repository.FindByUser(userID)
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
-	responses.JSON(w, http.StatusOK, posts)
+	responses.JSON(w, r, http.StatusOK, posts)
 }
 
 func LikePost(w http.ResponseWriter, r *http.Request) {
@@ -235,24 +367,31 @@ func LikePost(w http.ResponseWriter, r *http.Request) {
 
 	postID, err := strconv.ParseUint(parameters["postId"], 10, 64)
 	if err != nil {
-		responses.Err(w, http.StatusBadRequest, err)
+		responses.Err(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	db, err := db.Connect()
+	db, err := db.ConnectContext(r.Context())
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 	defer db.Close()
 
 	repository := repositories.NewPostRepository(db)
-	if err = repository.Like(postID); err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+	found, err := repository.Like(postID)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !found {
+		responses.Err(w, r, http.StatusNotFound, errors.New("post not found"))
 		return
 	}
+	metrics.PostLikes.Inc()
 
-	responses.JSON(w, http.StatusNoContent, nil)
+	responses.JSON(w, r, http.StatusNoContent, nil)
 }
 
 func DislikePost(w http.ResponseWriter, r *http.Request) {
@@ -260,22 +399,28 @@ func DislikePost(w http.ResponseWriter, r *http.Request) {
 
 	postID, err := strconv.ParseUint(parameters["postId"], 10, 64)
 	if err != nil {
-		responses.Err(w, http.StatusBadRequest, err)
+		responses.Err(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	db, err := db.Connect()
+	db, err := db.ConnectContext(r.Context())
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 	defer db.Close()
 
 	repository := repositories.NewPostRepository(db)
-	if err = repository.Dislike(postID); err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+	found, err := repository.Dislike(postID)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !found {
+		responses.Err(w, r, http.StatusNotFound, errors.New("post not found"))
 		return
 	}
 
-	responses.JSON(w, http.StatusNoContent, nil)
+	responses.JSON(w, r, http.StatusNoContent, nil)
 }
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"api/src/authentication"
+	"api/src/clientip"
+	"api/src/db"
+	"api/src/models"
+	"api/src/repositories"
+	"api/src/responses"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maxUserAgentLength matches the sessions.user_agent column, in characters.
+const maxUserAgentLength = 512
+
+func FindSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := ownAccount(w, r)
+	if !ok {
+		return
+	}
+
+	currentSessionID, err := authentication.ExtractSessionID(r)
+	if err != nil {
+		responses.Err(w, r, http.StatusUnauthorized, err)
+		return
+	}
+
+	db, err := db.ConnectContext(r.Context())
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	sessions, err := repositories.NewSessionRepository(db).FindByUser(userID)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+
+	responses.JSON(w, r, http.StatusOK, sessions)
+}
+
+func RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := ownAccount(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, err := strconv.ParseUint(mux.Vars(r)["sessionId"], 10, 64)
+	if err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	db, err := db.ConnectContext(r.Context())
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	revoked, err := repositories.NewSessionRepository(db).Revoke(userID, sessionID)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !revoked {
+		responses.Err(w, r, http.StatusNotFound, errors.New("session not found"))
+		return
+	}
+
+	responses.JSON(w, r, http.StatusNoContent, nil)
+}
+
+// RevokeOtherSessions signs the user out everywhere but the current session.
+func RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := ownAccount(w, r)
+	if !ok {
+		return
+	}
+
+	currentSessionID, err := authentication.ExtractSessionID(r)
+	if err != nil {
+		responses.Err(w, r, http.StatusUnauthorized, err)
+		return
+	}
+
+	db, err := db.ConnectContext(r.Context())
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	if _, err = repositories.NewSessionRepository(db).RevokeOthers(userID, currentSessionID); err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	responses.JSON(w, r, http.StatusNoContent, nil)
+}
+
+// startSession records the device the user logged in from and issues an
+// access token bound to that session.
+func startSession(db *sql.DB, r *http.Request, userID, tokenVersion uint64) (string, error) {
+	userAgent := truncate(strings.ToValidUTF8(r.UserAgent(), ""), maxUserAgentLength)
+
+	sessionID, err := repositories.NewSessionRepository(db).Create(models.Session{
+		UserID:    userID,
+		UserAgent: userAgent,
+		IP:        clientip.FromRequest(r),
+		ExpiresAt: time.Now().Add(authentication.TokenLifetime),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return authentication.CreateToken(userID, tokenVersion, sessionID)
+}
+
+// truncate cuts value to at most max characters, never inside one.
+func truncate(value string, max int) string {
+	count := 0
+	for i := range value {
+		if count == max {
+			return value[:i]
+		}
+		count++
+	}
+
+	return value
+}
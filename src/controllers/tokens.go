@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"api/src/authentication"
+	"api/src/db"
+	"api/src/models"
+	"api/src/repositories"
+	"api/src/requests"
+	"api/src/responses"
+	"api/src/security"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+func CreatePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := ownAccount(w, r)
+	if !ok {
+		return
+	}
+
+	var token models.PersonalAccessToken
+	err := requests.DecodeJSON(r, &token)
+	if err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = token.Prepare(); err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	token.UserID = userID
+	token.Token, token.Prefix, err = authentication.GeneratePersonalAccessToken()
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	db, err := db.ConnectContext(r.Context())
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	repository := repositories.NewPersonalAccessTokenRepository(db)
+	tokenID, err := repository.Create(token, security.HashToken(token.Token))
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	created, err := repository.FindById(tokenID)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	// The token itself is only ever shown in this response.
+	created.Token = token.Token
+	responses.JSON(w, r, http.StatusCreated, created)
+}
+
+func FindPersonalAccessTokens(w http.ResponseWriter, r *http.Request) {
+	userID, ok := ownAccount(w, r)
+	if !ok {
+		return
+	}
+
+	db, err := db.ConnectContext(r.Context())
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	tokens, err := repositories.NewPersonalAccessTokenRepository(db).FindByUser(userID)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	responses.JSON(w, r, http.StatusOK, tokens)
+}
+
+func RevokePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := ownAccount(w, r)
+	if !ok {
+		return
+	}
+
+	tokenID, err := strconv.ParseUint(mux.Vars(r)["tokenId"], 10, 64)
+	if err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	db, err := db.ConnectContext(r.Context())
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	revoked, err := repositories.NewPersonalAccessTokenRepository(db).Revoke(userID, tokenID)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !revoked {
+		responses.Err(w, r, http.StatusNotFound, errors.New("token not found"))
+		return
+	}
+
+	responses.JSON(w, r, http.StatusNoContent, nil)
+}
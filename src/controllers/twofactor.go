@@ -0,0 +1,356 @@
+package controllers
+
+import (
+	"api/src/authentication"
+	"api/src/config"
+	"api/src/db"
+	"api/src/lockout"
+	"api/src/metrics"
+	"api/src/models"
+	"api/src/repositories"
+	"api/src/requests"
+	"api/src/responses"
+	"api/src/security"
+	"api/src/totp"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const recoveryCodeCount = 10
+
+var errInvalidSecondFactor = errors.New("invalid two-factor code")
+
+func EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := ownAccount(w, r)
+	if !ok {
+		return
+	}
+
+	db, err := db.ConnectContext(r.Context())
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	repository := repositories.NewUserRepository(db)
+	_, enabled, err := repository.GetTOTP(userID)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if enabled {
+		responses.Err(w, r, http.StatusConflict, errors.New("two-factor authentication is already enabled"))
+		return
+	}
+
+	user, err := repository.FindById(userID)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = repository.SetPendingTOTPSecret(userID, secret); err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	responses.JSON(w, r, http.StatusOK, models.TwoFactor{
+		Secret: secret,
+		URI:    totp.URI(config.TwoFactorIssuer, user.Email, secret),
+	})
+}
+
+func ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := ownAccount(w, r)
+	if !ok {
+		return
+	}
+
+	var request models.TwoFactor
+	if !decodeTwoFactor(w, r, &request) {
+		return
+	}
+
+	db, err := db.ConnectContext(r.Context())
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	repository := repositories.NewUserRepository(db)
+	secret, enabled, err := repository.GetTOTP(userID)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if enabled {
+		responses.Err(w, r, http.StatusConflict, errors.New("two-factor authentication is already enabled"))
+		return
+	}
+
+	if secret == "" {
+		responses.Err(w, r, http.StatusBadRequest, errors.New("start the enrollment first"))
+		return
+	}
+
+	step, valid := totp.Validate(secret, request.Code, time.Now())
+	if !valid {
+		responses.Err(w, r, http.StatusBadRequest, errInvalidSecondFactor)
+		return
+	}
+
+	if _, err = repository.UseTOTPStep(userID, step); err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = repositories.NewRecoveryCodeRepository(db).Replace(userID, hashes); err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = repository.EnableTOTP(userID); err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	responses.JSON(w, r, http.StatusOK, models.TwoFactor{RecoveryCodes: codes})
+}
+
+func DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := ownAccount(w, r)
+	if !ok {
+		return
+	}
+
+	var request models.TwoFactor
+	if !decodeTwoFactor(w, r, &request) {
+		return
+	}
+
+	db, err := db.ConnectContext(r.Context())
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	repository := repositories.NewUserRepository(db)
+	passwordFromDb, err := repository.GetPassword(userID)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = security.VerifyPassword(passwordFromDb, request.Password); err != nil {
+		responses.Err(w, r, http.StatusUnauthorized, errors.New("current password is incorrect"))
+		return
+	}
+
+	secret, enabled, err := repository.GetTOTP(userID)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !enabled {
+		responses.Err(w, r, http.StatusConflict, errors.New("two-factor authentication is not enabled"))
+		return
+	}
+
+	valid, err := verifySecondFactor(db, userID, secret, request)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !valid {
+		responses.Err(w, r, http.StatusUnauthorized, errInvalidSecondFactor)
+		return
+	}
+
+	if err = repository.DisableTOTP(userID); err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = repositories.NewRecoveryCodeRepository(db).DeleteByUser(userID); err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	responses.JSON(w, r, http.StatusNoContent, nil)
+}
+
+func LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var request models.TwoFactor
+	if !decodeTwoFactor(w, r, &request) {
+		return
+	}
+
+	userID, tokenVersion, err := authentication.ParseChallengeToken(request.ChallengeToken)
+	if err != nil {
+		responses.Err(w, r, http.StatusUnauthorized, errors.New("invalid or expired challenge token"))
+		return
+	}
+
+	attemptKey := "2fa:" + strconv.FormatUint(userID, 10)
+	retryAfter, err := lockout.Accounts.Attempt(attemptKey)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if retryAfter > 0 {
+		metrics.LoginFailures.Inc("locked_out")
+		w.Header().Set("Retry-After", fmt.Sprintf("%.0f", math.Ceil(retryAfter.Seconds())))
+		responses.Err(w, r, http.StatusTooManyRequests, errTooManyAttempts)
+		return
+	}
+
+	db, err := db.ConnectContext(r.Context())
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	repository := repositories.NewUserRepository(db)
+	currentVersion, err := repository.GetTokenVersion(userID)
+	if err == sql.ErrNoRows || (err == nil && currentVersion != tokenVersion) {
+		responses.Err(w, r, http.StatusUnauthorized, errors.New("invalid or expired challenge token"))
+		return
+	}
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	secret, enabled, err := repository.GetTOTP(userID)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	valid := false
+	if enabled {
+		if valid, err = verifySecondFactor(db, userID, secret, request); err != nil {
+			responses.Err(w, r, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	if !valid {
+		if err = lockout.Accounts.Fail(attemptKey); err != nil {
+			responses.Err(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		metrics.LoginFailures.Inc("second_factor")
+		responses.Err(w, r, http.StatusUnauthorized, errInvalidSecondFactor)
+		return
+	}
+
+	if err = lockout.Accounts.Reset(attemptKey); err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	token, err := startSession(db, r, userID, tokenVersion)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	responses.JSON(w, r, http.StatusOK, models.AuthenticationData{ID: strconv.FormatUint(userID, 10), Token: token})
+}
+
+// verifySecondFactor accepts either a TOTP code, which can't be replayed, or
+// an unused recovery code, which is then spent.
+func verifySecondFactor(db *sql.DB, userID uint64, secret string, request models.TwoFactor) (bool, error) {
+	if request.Code != "" {
+		step, valid := totp.Validate(secret, request.Code, time.Now())
+		if !valid {
+			return false, nil
+		}
+
+		return repositories.NewUserRepository(db).UseTOTPStep(userID, step)
+	}
+
+	if request.RecoveryCode != "" {
+		codeHash := security.HashToken(security.NormalizeRecoveryCode(request.RecoveryCode))
+		return repositories.NewRecoveryCodeRepository(db).Use(userID, codeHash)
+	}
+
+	return false, nil
+}
+
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := security.GenerateRecoveryCode()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		codes = append(codes, code)
+		hashes = append(hashes, security.HashToken(security.NormalizeRecoveryCode(code)))
+	}
+
+	return codes, hashes, nil
+}
+
+// ownAccount checks that the {userId} of the route is the authenticated user.
+func ownAccount(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	userID, err := strconv.ParseUint(mux.Vars(r)["userId"], 10, 64)
+	if err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return 0, false
+	}
+
+	userIDOnToken, err := authentication.ExtractUserId(r)
+	if err != nil {
+		responses.Err(w, r, http.StatusUnauthorized, err)
+		return 0, false
+	}
+
+	if userID != userIDOnToken {
+		responses.Err(w, r, http.StatusForbidden, errors.New("you can only manage your own account"))
+		return 0, false
+	}
+
+	return userID, true
+}
+
+func decodeTwoFactor(w http.ResponseWriter, r *http.Request, request *models.TwoFactor) bool {
+	if err := requests.DecodeJSON(r, request); err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return false
+	}
+
+	return true
+}
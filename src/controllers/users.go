@@ -3,13 +3,19 @@ package controllers
 import (
 	"api/src/authentication"
 	"api/src/db"
+	"api/src/etag"
+	"api/src/logger"
+	"api/src/metrics"
 	"api/src/models"
+	"api/src/passwordpolicy"
+	"api/src/patch"
 	"api/src/repositories"
+	"api/src/requests"
 	"api/src/responses"
 	"api/src/security"
-	"encoding/json"
+	"api/src/validation"
+	"api/src/versioning"
 	"errors"
-	"io/ioutil"
 	"net/http"
 	"strconv"
 	"strings"
@@ -18,45 +24,48 @@ import (
 )
 
 func CreateUser(w http.ResponseWriter, r *http.Request) {
-	requestBody, err := ioutil.ReadAll(r.Body)
+	var user models.User
+	err := requests.DecodeJSON(r, &user)
 	if err != nil {
-		responses.Err(w, http.StatusUnprocessableEntity, err)
+		responses.Err(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	var user models.User
-	if err := json.Unmarshal(requestBody, &user); err != nil {
-		responses.Err(w, http.StatusBadRequest, err)
-	}
-
 	var stage = "register"
 
 	if err = user.Prepare(stage); err != nil {
-		responses.Err(w, http.StatusBadRequest, err)
+		responses.Err(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	db, err := db.Connect()
+	db, err := db.ConnectContext(r.Context())
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
-
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
 	}
+	defer db.Close()
 
 	repository := repositories.NewUserRepository(db)
 	user.ID, err = repository.Create(user)
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
 	}
+	metrics.UsersRegistered.Inc()
 
-	responses.JSON(w, http.StatusCreated, user)
+	if err = sendVerificationEmail(user); err != nil {
+		logger.FromContext(r.Context()).Error("sending verification email", "user_id", user.ID, "error", err)
+	}
+
+	responses.JSON(w, r, http.StatusCreated, user)
 
 }
 
 func FindAll(w http.ResponseWriter, r *http.Request) {
 	nameOrNickname := strings.ToLower(r.URL.Query().Get("user"))
-	db, err := db.Connect()
+	db, err := db.ConnectContext(r.Context())
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 	defer db.Close()
@@ -64,23 +73,24 @@ func FindAll(w http.ResponseWriter, r *http.Request) {
 	repository := repositories.NewUserRepository(db)
 	users, err := repository.Search(nameOrNickname)
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
-	responses.JSON(w, http.StatusOK, users)
+	responses.JSON(w, r, http.StatusOK, presentUsers(r, users))
 }
 
 func FindUser(w http.ResponseWriter, r *http.Request) {
 	parameters := mux.Vars(r)
 	userId, err := strconv.ParseUint(parameters["userId"], 10, 64)
 	if err != nil {
-		responses.Err(w, http.StatusBadRequest, err)
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
 	}
 
-	db, err := db.Connect()
+	db, err := db.ConnectContext(r.Context())
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 	defer db.Close()
@@ -88,179 +98,316 @@ func FindUser(w http.ResponseWriter, r *http.Request) {
 	repository := repositories.NewUserRepository(db)
 	user, err := repository.FindById(userId)
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if user.ID == 0 {
+		responses.Err(w, r, http.StatusNotFound, errors.New("user not found"))
+		return
+	}
+
+	etag.Set(w, user.Version)
+	if etag.NotModified(r, user.Version) {
+		responses.JSON(w, r, http.StatusNotModified, nil)
 		return
 	}
 
-	responses.JSON(w, http.StatusOK, user)
+	responses.JSON(w, r, http.StatusOK, presentUsers(r, []models.User{user})[0])
 }
 
 func UpdateUser(w http.ResponseWriter, r *http.Request) {
 	parameters := mux.Vars(r)
 	userId, err := strconv.ParseUint(parameters["userId"], 10, 64)
 	if err != nil {
-		responses.Err(w, http.StatusBadRequest, err)
+		responses.Err(w, r, http.StatusBadRequest, err)
 		return
 	}
 
 	userIdOnToken, err := authentication.ExtractUserId(r)
 	if err != nil {
-		responses.Err(w, http.StatusUnauthorized, err)
+		responses.Err(w, r, http.StatusUnauthorized, err)
 		return
 	}
 
 	if userId != userIdOnToken {
-		responses.Err(w, http.StatusForbidden, errors.New("you can only update your own account"))
+		responses.Err(w, r, http.StatusForbidden, errors.New("you can only update your own account"))
+		return
+	}
+
+	var user models.User
+	if err = requests.DecodeJSON(r, &user); err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	requestBody, err := ioutil.ReadAll(r.Body)
+	if err = user.Prepare("edition"); err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	db, err := db.ConnectContext(r.Context())
 	if err != nil {
-		responses.Err(w, http.StatusUnprocessableEntity, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
+	defer db.Close()
 
-	var user models.User
-	if err := json.Unmarshal(requestBody, &user); err != nil {
-		responses.Err(w, http.StatusBadRequest, err)
+	repository := repositories.NewUserRepository(db)
+	userSavedOnDb, err := repository.FindById(userId)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
-	if err = user.Prepare("edition"); err != nil {
-		responses.Err(w, http.StatusBadRequest, err)
+	if userSavedOnDb.ID == 0 {
+		responses.Err(w, r, http.StatusNotFound, errors.New("user not found"))
+		return
+	}
+
+	if !etag.Matches(r, userSavedOnDb.Version) {
+		responses.Err(w, r, http.StatusPreconditionFailed, etag.ErrPreconditionFailed)
+		return
+	}
+
+	updated, err := repository.Update(userId, user, userSavedOnDb.Version)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !updated {
+		responses.Err(w, r, http.StatusConflict, etag.Stale(r))
+		return
+	}
+
+	confirmNewEmail(r, userSavedOnDb, user)
+
+	etag.Set(w, userSavedOnDb.Version+1)
+	responses.JSON(w, r, http.StatusNoContent, nil)
+}
+
+func PatchUser(w http.ResponseWriter, r *http.Request) {
+	parameters := mux.Vars(r)
+	userId, err := strconv.ParseUint(parameters["userId"], 10, 64)
+	if err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	userIdOnToken, err := authentication.ExtractUserId(r)
+	if err != nil {
+		responses.Err(w, r, http.StatusUnauthorized, err)
+		return
+	}
+
+	if userId != userIdOnToken {
+		responses.Err(w, r, http.StatusForbidden, errors.New("you can only update your own account"))
+		return
+	}
+
+	requestBody, err := requests.ReadBody(r)
+	if err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	db, err := db.Connect()
+	db, err := db.ConnectContext(r.Context())
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 	defer db.Close()
 
 	repository := repositories.NewUserRepository(db)
-	if err = repository.Update(userId, user); err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+	userSavedOnDb, err := repository.FindById(userId)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if userSavedOnDb.ID == 0 {
+		responses.Err(w, r, http.StatusNotFound, errors.New("user not found"))
+		return
+	}
+
+	if !etag.Matches(r, userSavedOnDb.Version) {
+		responses.Err(w, r, http.StatusPreconditionFailed, etag.ErrPreconditionFailed)
+		return
+	}
+
+	var user models.User
+	fields, err := patch.Apply(r.Header.Get("Content-Type"), userSavedOnDb, []string{"name", "nickname", "email"}, requestBody, &user)
+	if err == patch.ErrUnsupportedContentType {
+		responses.Err(w, r, http.StatusUnsupportedMediaType, err)
+		return
+	}
+	if err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = user.PrepareFields(fields); err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	updated, err := repository.UpdateFields(userId, user, fields, userSavedOnDb.Version)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
-	responses.JSON(w, http.StatusNoContent, nil)
+	if !updated {
+		responses.Err(w, r, http.StatusConflict, etag.Stale(r))
+		return
+	}
+
+	confirmNewEmail(r, userSavedOnDb, user)
+
+	if len(fields) > 0 {
+		etag.Set(w, userSavedOnDb.Version+1)
+	}
+	responses.JSON(w, r, http.StatusNoContent, nil)
 }
 
 func DeleteUser(w http.ResponseWriter, r *http.Request) {
 	parameters := mux.Vars(r)
 	userId, err := strconv.ParseUint(parameters["userId"], 10, 64)
 	if err != nil {
-		responses.Err(w, http.StatusBadRequest, err)
+		responses.Err(w, r, http.StatusBadRequest, err)
 		return
 	}
 
 	userIdOnToken, err := authentication.ExtractUserId(r)
 	if err != nil {
-		responses.Err(w, http.StatusUnauthorized, err)
+		responses.Err(w, r, http.StatusUnauthorized, err)
 		return
 	}
 
 	if userId != userIdOnToken {
-		responses.Err(w, http.StatusForbidden, errors.New("you can only deletee your own account"))
+		responses.Err(w, r, http.StatusForbidden, errors.New("you can only deletee your own account"))
 		return
 	}
 
-	db, err := db.Connect()
+	db, err := db.ConnectContext(r.Context())
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 	defer db.Close()
 
 	repository := repositories.NewUserRepository(db)
-	if err = repository.Delete(userId); err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+	user, err := repository.FindById(userId)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
-	responses.JSON(w, http.StatusNoContent, nil)
+	if user.ID == 0 {
+		responses.Err(w, r, http.StatusNotFound, errors.New("user not found"))
+		return
+	}
+
+	if !etag.Matches(r, user.Version) {
+		responses.Err(w, r, http.StatusPreconditionFailed, etag.ErrPreconditionFailed)
+		return
+	}
+
+	deleted, err := repository.SoftDelete(userId, user.Version)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !deleted {
+		responses.Err(w, r, http.StatusConflict, etag.Stale(r))
+		return
+	}
+
+	responses.JSON(w, r, http.StatusNoContent, nil)
 }
 
 func FollowUser(w http.ResponseWriter, r *http.Request) {
 	followerID, err := authentication.ExtractUserId(r)
 	if err != nil {
-		responses.Err(w, http.StatusUnauthorized, err)
+		responses.Err(w, r, http.StatusUnauthorized, err)
 		return
 	}
 
 	parameters := mux.Vars(r)
 	userId, err := strconv.ParseUint(parameters["userId"], 10, 64)
 	if err != nil {
-		responses.Err(w, http.StatusBadRequest, err)
+		responses.Err(w, r, http.StatusBadRequest, err)
 		return
 	}
 
 	if followerID == userId {
-		responses.Err(w, http.StatusForbidden, errors.New("you can't follow yourself"))
+		responses.Err(w, r, http.StatusForbidden, errors.New("you can't follow yourself"))
 	}
 
-	db, err := db.Connect()
+	db, err := db.ConnectContext(r.Context())
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 	defer db.Close()
 
 	repository := repositories.NewUserRepository(db)
 	if err = repository.Follow(followerID, userId); err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
+	metrics.Follows.Inc()
 
-	responses.JSON(w, http.StatusNoContent, nil)
+	responses.JSON(w, r, http.StatusNoContent, nil)
 }
 
 func UnfollowUser(w http.ResponseWriter, r *http.Request) {
 	followerID, err := authentication.ExtractUserId(r)
 	if err != nil {
-		responses.Err(w, http.StatusUnauthorized, err)
+		responses.Err(w, r, http.StatusUnauthorized, err)
 		return
 	}
 
 	parameters := mux.Vars(r)
 	userId, err := strconv.ParseUint(parameters["userId"], 10, 64)
 	if err != nil {
-		responses.Err(w, http.StatusBadRequest, err)
+		responses.Err(w, r, http.StatusBadRequest, err)
 		return
 	}
 
 	if followerID == userId {
-		responses.Err(w, http.StatusForbidden, errors.New("you can't unfollow yourself"))
+		responses.Err(w, r, http.StatusForbidden, errors.New("you can't unfollow yourself"))
 	}
 
-	db, err := db.Connect()
+	db, err := db.ConnectContext(r.Context())
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 	defer db.Close()
 
 	repository := repositories.NewUserRepository(db)
 	if err = repository.Unfollow(userId, followerID); err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
-	responses.JSON(w, http.StatusNoContent, nil)
+	responses.JSON(w, r, http.StatusNoContent, nil)
 }
 
 func GetFollowers(w http.ResponseWriter, r *http.Request) {
 	parameters := mux.Vars(r)
 	userId, err := strconv.ParseUint(parameters["userId"], 10, 64)
 	if err != nil {
-		responses.Err(w, http.StatusBadRequest, err)
+		responses.Err(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	db, err := db.Connect()
+	db, err := db.ConnectContext(r.Context())
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 	defer db.Close()
@@ -268,24 +415,24 @@ func GetFollowers(w http.ResponseWriter, r *http.Request) {
 	repository := repositories.NewUserRepository(db)
 	followers, err := repository.GetFollowers(userId)
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
-	responses.JSON(w, http.StatusOK, followers)
+	responses.JSON(w, r, http.StatusOK, presentUsers(r, followers))
 }
 
 func GetFollowing(w http.ResponseWriter, r *http.Request) {
 	parameters := mux.Vars(r)
 	userId, err := strconv.ParseUint(parameters["userId"], 10, 64)
 	if err != nil {
-		responses.Err(w, http.StatusBadRequest, err)
+		responses.Err(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	db, err := db.Connect()
+	db, err := db.ConnectContext(r.Context())
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 	defer db.Close()
@@ -293,71 +440,102 @@ func GetFollowing(w http.ResponseWriter, r *http.Request) {
 	repository := repositories.NewUserRepository(db)
 	following, err := repository.GetFollowing(userId)
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
-	responses.JSON(w, http.StatusOK, following)
+	responses.JSON(w, r, http.StatusOK, presentUsers(r, following))
 }
 
 func UpdatePassword(w http.ResponseWriter, r *http.Request) {
 	userIdOnToken, err := authentication.ExtractUserId(r)
 	if err != nil {
-		responses.Err(w, http.StatusUnauthorized, err)
+		responses.Err(w, r, http.StatusUnauthorized, err)
 		return
 	}
 
 	parameters := mux.Vars(r)
 	userID, err := strconv.ParseUint(parameters["userId"], 10, 64)
 	if err != nil {
-		responses.Err(w, http.StatusBadRequest, err)
+		responses.Err(w, r, http.StatusBadRequest, err)
 	}
 
 	if userIdOnToken != userID {
-		responses.Err(w, http.StatusForbidden, errors.New("you can only update your own password"))
+		responses.Err(w, r, http.StatusForbidden, errors.New("you can only update your own password"))
 		return
 	}
 
-	requestBody, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		responses.Err(w, http.StatusBadRequest, err)
+	var password models.Password
+	if err = requests.DecodeJSON(r, &password); err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	var password models.Password
-	if err = json.Unmarshal(requestBody, &password); err != nil {
-		responses.Err(w, http.StatusBadRequest, err)
+	err = validation.New().
+		Field("current", password.Current, validation.Required()).
+		Field("new", password.New, validation.Required()).
+		Err()
+	if err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	db, err := db.Connect()
+	db, err := db.ConnectContext(r.Context())
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
 	repository := repositories.NewUserRepository(db)
 	passwordFromDb, err := repository.GetPassword(userID)
 	if err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
 	if err = security.VerifyPassword(passwordFromDb, password.Current); err != nil {
-		responses.Err(w, http.StatusUnauthorized, errors.New("current password is incorrect"))
+		responses.Err(w, r, http.StatusUnauthorized, errors.New("current password is incorrect"))
+		return
+	}
+
+	user, err := repository.FindById(userID)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = passwordpolicy.Check(password.New, user.Name, user.Nickname, user.Email); err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
 		return
 	}
 
 	hashedPassword, err := security.Hash(password.New)
 	if err != nil {
-		responses.Err(w, http.StatusBadRequest, err)
+		responses.Err(w, r, http.StatusBadRequest, err)
 		return
 	}
 
 	if err = repository.UpdatePassword(userID, string(hashedPassword)); err != nil {
-		responses.Err(w, http.StatusInternalServerError, err)
+		responses.Err(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
-	responses.JSON(w, http.StatusNoContent, nil)
+	responses.JSON(w, r, http.StatusNoContent, nil)
+}
+
+// presentUsers hides, from version 2 of the API on, the email addresses of
+// other users than the caller.
+func presentUsers(r *http.Request, users []models.User) []models.User {
+	if versioning.FromContext(r.Context()) < versioning.V2 {
+		return users
+	}
+
+	callerID, _ := authentication.ExtractUserId(r)
+	for i := range users {
+		if users[i].ID != callerID {
+			users[i].Email = ""
+		}
+	}
+
+	return users
 }
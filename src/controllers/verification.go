@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"api/src/authentication"
+	"api/src/config"
+	"api/src/db"
+	"api/src/logger"
+	"api/src/mailer"
+	"api/src/models"
+	"api/src/repositories"
+	"api/src/requests"
+	"api/src/responses"
+	"api/src/validation"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+func VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var verification models.Verification
+	err := requests.DecodeJSON(r, &verification)
+	if err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, email, err := authentication.ParseVerificationToken(verification.Token)
+	if err != nil {
+		responses.Err(w, r, http.StatusBadRequest, errors.New("invalid or expired verification token"))
+		return
+	}
+
+	db, err := db.ConnectContext(r.Context())
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	repository := repositories.NewUserRepository(db)
+	if _, err = repository.Verify(userID, email); err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	user, err := repository.FindById(userID)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if user.Email != email || user.VerifiedAt == nil {
+		responses.Err(w, r, http.StatusBadRequest, errors.New("invalid or expired verification token"))
+		return
+	}
+
+	responses.JSON(w, r, http.StatusNoContent, nil)
+}
+
+func ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var verification models.Verification
+	err := requests.DecodeJSON(r, &verification)
+	if err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = validation.New().Field("email", verification.Email, validation.Required(), validation.Email()).Err(); err != nil {
+		responses.Err(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	db, err := db.ConnectContext(r.Context())
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	repository := repositories.NewUserRepository(db)
+	user, err := repository.FindByEmail(verification.Email)
+	if err != nil {
+		responses.Err(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	// The answer is the same whether or not the address is registered, so this
+	// endpoint can't be used to discover accounts. The email is sent in the
+	// background, so the response time doesn't tell either.
+	if user.ID != 0 && user.VerifiedAt == nil && user.DeletedAt == nil {
+		requestLogger := logger.FromContext(r.Context())
+		go func() {
+			if err := sendVerificationEmail(user); err != nil {
+				requestLogger.Error("sending verification email", "user_id", user.ID, "error", err)
+			}
+		}()
+	}
+
+	responses.JSON(w, r, http.StatusAccepted, nil)
+}
+
+// confirmNewEmail sends a verification email when an update changed the
+// email of the user, which the update left unverified.
+func confirmNewEmail(r *http.Request, before, after models.User) {
+	if after.Email == before.Email {
+		return
+	}
+
+	after.ID = before.ID
+	if err := sendVerificationEmail(after); err != nil {
+		logger.FromContext(r.Context()).Error("sending verification email", "user_id", after.ID, "error", err)
+	}
+}
+
+func sendVerificationEmail(user models.User) error {
+	token, err := authentication.CreateVerificationToken(user.ID, user.Email)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", config.AppURL, url.QueryEscape(token))
+
+	return mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nConfirm your email address by opening the link below:\n\n%s\n\nOr send this token to POST /users/verify:\n\n%s\n\nThe link expires in %s.\n",
+			user.Name, link, token, config.VerificationTokenTTL,
+		),
+	})
+}
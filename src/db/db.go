@@ -2,20 +2,46 @@ package db
 
 import (
 	"api/src/config"
+	"api/src/tracing"
+	"context"
 	"database/sql"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
+// Connect opens a connection pool outside of any request, e.g. for jobs.
 func Connect() (*sql.DB, error) {
-	db, err := sql.Open("mysql", config.StringDbConnection)
+	return ConnectContext(context.Background())
+}
+
+// ConnectContext opens a connection pool for the work of ctx. Every request
+// opens its own pool, so the queries made through it are traced as part of
+// the request.
+func ConnectContext(ctx context.Context) (*sql.DB, error) {
+	_, span := tracing.Start(ctx, "db.Connect", tracing.KindClient)
+	defer span.End()
+
+	mysqlConfig, err := mysql.ParseDSN(config.StringDbConnection)
+	if err != nil {
+		span.SetError(err.Error())
+		return nil, err
+	}
+
+	mysqlConnector, err := mysql.NewConnector(mysqlConfig)
 	if err != nil {
+		span.SetError(err.Error())
 		return nil, err
 	}
 
+	connector := &instrumentedConnector{Connector: mysqlConnector, ctx: ctx}
+	db := sql.OpenDB(connector)
+	connector.db = db
 	if err = db.Ping(); err != nil {
+		span.SetError(err.Error())
+		db.Close()
 		return nil, err
 	}
+	track(db)
 
 	return db, nil
 }
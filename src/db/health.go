@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// schema lists the tables, and the columns added to existing tables, the
+// code relies on. Extend it with every change to sql/sql.sql, so instances
+// don't get traffic before the database is migrated.
+var schema = []string{
+	"users",
+	"users.verified_at",
+	"users.token_version",
+	"users.deleted_at",
+	"users.is_admin",
+	"users.totp_secret",
+	"users.totp_enabled",
+	"users.totp_last_step",
+	"users.version",
+	"followers",
+	"posts",
+	"posts.version",
+	"password_resets",
+	"data_exports",
+	"login_attempts",
+	"recovery_codes",
+	"user_identities",
+	"personal_access_tokens",
+	"sessions",
+	"sessions.expires_at",
+	"idempotency_keys",
+	"idempotency_keys.locked_until",
+}
+
+// Ping checks that the database accepts connections.
+func Ping(ctx context.Context) error {
+	db, err := ConnectContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	return db.Close()
+}
+
+// CheckSchema checks that the database has every table and column in schema.
+func CheckSchema(ctx context.Context) error {
+	db, err := ConnectContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx,
+		"select table_name, column_name from information_schema.columns where table_schema = database()",
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	found := make(map[string]bool)
+	for rows.Next() {
+		var table, column string
+		if err = rows.Scan(&table, &column); err != nil {
+			return err
+		}
+
+		found[table] = true
+		found[table+"."+column] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, name := range schema {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("the database isn't migrated, missing %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
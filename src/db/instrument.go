@@ -0,0 +1,247 @@
+package db
+
+import (
+	"api/src/logger"
+	"api/src/metrics"
+	"api/src/tracing"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// observeQuery starts timing and tracing a query, named after the repository
+// method that runs it. The returned function ends it, and logs a failure
+// with the logger of the request the pool was opened for.
+func observeQuery(ctx context.Context, query string) func(error) {
+	repository, method := caller()
+
+	_, span := tracing.Start(ctx, repository+"."+method, tracing.KindClient)
+	span.SetAttributes(
+		tracing.String("db.system", "mysql"),
+		tracing.String("db.statement", tracing.SanitizeSQL(query)),
+	)
+	start := time.Now()
+
+	return func(err error) {
+		// The driver declined the query, which database/sql retries as a
+		// prepared statement, observed on its own.
+		if err == driver.ErrSkip {
+			return
+		}
+
+		metrics.DBQueryDuration.Observe(time.Since(start).Seconds(), repository, method)
+		if err != nil {
+			metrics.DBQueryErrors.Inc(repository, method)
+			span.SetError(err.Error())
+			logger.FromContext(ctx).Warn("query failed",
+				"repository", repository,
+				"method", method,
+				"statement", tracing.SanitizeSQL(query),
+				"error", err,
+			)
+		}
+		span.End()
+	}
+}
+
+// caller finds the first function of the application up the stack, e.g.
+// "api/src/repositories.Users.Create" gives "Users" and "Create". Queries
+// made outside of repositories are labeled with their package.
+func caller() (string, string) {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+
+	for {
+		frame, more := frames.Next()
+		if strings.HasPrefix(frame.Function, "api/src/") && !strings.HasPrefix(frame.Function, "api/src/db.") {
+			name := strings.TrimPrefix(frame.Function, "api/src/")
+			pkg := name[:strings.Index(name, ".")]
+			parts := strings.Split(strings.Trim(strings.TrimPrefix(name, pkg+"."), "()*"), ".")
+
+			if pkg == "repositories" && len(parts) >= 2 {
+				return strings.Trim(parts[0], "()*"), parts[1]
+			}
+			return pkg, strings.Join(parts, ".")
+		}
+
+		if !more {
+			return "unknown", "unknown"
+		}
+	}
+}
+
+// instrumentedConnector hands out connections that trace their queries under
+// the context the pool was opened for.
+type instrumentedConnector struct {
+	driver.Connector
+	ctx context.Context
+	db  *sql.DB
+}
+
+// Close is called by sql.DB.Close, and stops counting the pool in Stats.
+func (connector *instrumentedConnector) Close() error {
+	untrack(connector.db)
+	return nil
+}
+
+func (connector instrumentedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := connector.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return instrumentedConn{Conn: conn, ctx: connector.ctx}, nil
+}
+
+type instrumentedConn struct {
+	driver.Conn
+	ctx context.Context
+}
+
+func (conn instrumentedConn) Prepare(query string) (driver.Stmt, error) {
+	return conn.PrepareContext(context.Background(), query)
+}
+
+func (conn instrumentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+
+	if preparer, ok := conn.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = conn.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return instrumentedStmt{Stmt: stmt, ctx: conn.ctx, query: query}, nil
+}
+
+func (conn instrumentedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := conn.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+
+	return conn.Conn.Begin()
+}
+
+func (conn instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := conn.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	done := observeQuery(conn.ctx, query)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	done(err)
+	return rows, err
+}
+
+func (conn instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := conn.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	done := observeQuery(conn.ctx, query)
+	result, err := execer.ExecContext(ctx, query, args)
+	done(err)
+	return result, err
+}
+
+func (conn instrumentedConn) Ping(ctx context.Context) error {
+	if pinger, ok := conn.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+
+	return nil
+}
+
+func (conn instrumentedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := conn.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+func (conn instrumentedConn) IsValid() bool {
+	if validator, ok := conn.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+
+	return true
+}
+
+func (conn instrumentedConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := conn.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+
+	return driver.ErrSkip
+}
+
+type instrumentedStmt struct {
+	driver.Stmt
+	ctx   context.Context
+	query string
+}
+
+func (stmt instrumentedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	done := observeQuery(stmt.ctx, stmt.query)
+	result, err := stmt.execContext(ctx, args)
+	done(err)
+	return result, err
+}
+
+func (stmt instrumentedStmt) execContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if execer, ok := stmt.Stmt.(driver.StmtExecContext); ok {
+		return execer.ExecContext(ctx, args)
+	}
+
+	values, err := namedValuesToValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.Stmt.Exec(values)
+}
+
+func (stmt instrumentedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	done := observeQuery(stmt.ctx, stmt.query)
+	rows, err := stmt.queryContext(ctx, args)
+	done(err)
+	return rows, err
+}
+
+func (stmt instrumentedStmt) queryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if queryer, ok := stmt.Stmt.(driver.StmtQueryContext); ok {
+		return queryer.QueryContext(ctx, args)
+	}
+
+	values, err := namedValuesToValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.Stmt.Query(values)
+}
+
+func (stmt instrumentedStmt) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := stmt.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+
+	return driver.ErrSkip
+}
+
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+
+	return values, nil
+}
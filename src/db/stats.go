@@ -0,0 +1,84 @@
+package db
+
+import (
+	"api/src/metrics"
+	"database/sql"
+	"sync"
+)
+
+// Every Connect opens its own pool, so the pool metrics add up the pools that
+// are still open. Closing a pool drops it, keeping its totals in retired.
+var (
+	poolsMu sync.Mutex
+	pools   = map[*sql.DB]struct{}{}
+	retired sql.DBStats
+	opened  int
+)
+
+func track(db *sql.DB) {
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+
+	pools[db] = struct{}{}
+	opened++
+}
+
+func untrack(db *sql.DB) {
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+
+	if _, ok := pools[db]; !ok {
+		return
+	}
+
+	delete(pools, db)
+	addTotals(&retired, db.Stats())
+}
+
+// Stats adds up the statistics of every pool opened by Connect.
+func Stats() sql.DBStats {
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+
+	total := retired
+	for db := range pools {
+		stats := db.Stats()
+		total.OpenConnections += stats.OpenConnections
+		total.InUse += stats.InUse
+		total.Idle += stats.Idle
+		addTotals(&total, stats)
+	}
+
+	return total
+}
+
+func addTotals(total *sql.DBStats, stats sql.DBStats) {
+	total.WaitCount += stats.WaitCount
+	total.WaitDuration += stats.WaitDuration
+	total.MaxIdleClosed += stats.MaxIdleClosed
+	total.MaxIdleTimeClosed += stats.MaxIdleTimeClosed
+	total.MaxLifetimeClosed += stats.MaxLifetimeClosed
+}
+
+func init() {
+	metrics.NewGaugeFunc("db_open_connections", "Open database connections, in use or idle.", func() float64 {
+		return float64(Stats().OpenConnections)
+	})
+	metrics.NewGaugeFunc("db_in_use_connections", "Database connections running a query.", func() float64 {
+		return float64(Stats().InUse)
+	})
+	metrics.NewGaugeFunc("db_idle_connections", "Idle database connections.", func() float64 {
+		return float64(Stats().Idle)
+	})
+	metrics.NewCounterFunc("db_wait_count_total", "Times a query waited for a free connection.", func() float64 {
+		return float64(Stats().WaitCount)
+	})
+	metrics.NewCounterFunc("db_wait_duration_seconds_total", "Time spent waiting for a free connection.", func() float64 {
+		return Stats().WaitDuration.Seconds()
+	})
+	metrics.NewCounterFunc("db_pools_opened_total", "Connection pools opened.", func() float64 {
+		poolsMu.Lock()
+		defer poolsMu.Unlock()
+		return float64(opened)
+	})
+}
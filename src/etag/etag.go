@@ -0,0 +1,92 @@
+// Package etag implements conditional requests over the version column of a
+// row. The ETag of a resource is its version, so it changes with every
+// update and clients can revalidate with If-None-Match and avoid lost
+// updates with If-Match.
+package etag
+
+import (
+	"api/src/apperrors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrPreconditionFailed answers an If-Match that doesn't hold.
+	ErrPreconditionFailed = apperrors.New(apperrors.KindPreconditionFailed, "precondition_failed",
+		"the resource was changed since you fetched it, fetch it again")
+	// ErrEditConflict answers an update without If-Match that lost the race
+	// against another update.
+	ErrEditConflict = apperrors.New(apperrors.KindConflict, "edit_conflict",
+		"the resource was changed while being updated, try again")
+)
+
+// For returns the ETag of the resource at version.
+func For(version uint64) string {
+	return `"` + strconv.FormatUint(version, 10) + `"`
+}
+
+// Set sets the ETag header of the response.
+func Set(w http.ResponseWriter, version uint64) {
+	w.Header().Set("ETag", For(version))
+}
+
+// NotModified reports whether the If-None-Match header of r lists the ETag
+// of version, so the client's copy can be reused.
+func NotModified(r *http.Request, version uint64) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	// If-None-Match uses the weak comparison, which ignores the W/ prefix.
+	tag := For(version)
+	for _, candidate := range list(header) {
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Matches reports whether the If-Match header of r holds for the resource at
+// version. Requests without If-Match always match.
+func Matches(r *http.Request, version uint64) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+
+	// If-Match uses the strong comparison, so weak tags never match.
+	tag := For(version)
+	for _, candidate := range list(header) {
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Stale is the error answered when a compare-and-swap update found the
+// resource changed: a failed precondition if the client set one, a conflict
+// otherwise.
+func Stale(r *http.Request) error {
+	if r.Header.Get("If-Match") != "" {
+		return ErrPreconditionFailed
+	}
+
+	return ErrEditConflict
+}
+
+func list(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
@@ -0,0 +1,182 @@
+package export
+
+import (
+	"api/src/config"
+	"api/src/db"
+	"api/src/logger"
+	"api/src/models"
+	"api/src/repositories"
+	"api/src/storage"
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// Archive is everything we hold about a user, as handed back on a
+// data-portability request.
+type Archive struct {
+	GeneratedAt time.Time             `json:"generated_at"`
+	Profile     models.User           `json:"profile"`
+	Posts       []models.Post         `json:"posts"`
+	Likes       []PostLikes           `json:"likes"`
+	Followers   []models.User         `json:"followers"`
+	Following   []models.User         `json:"following"`
+	Events      []models.AccountEvent `json:"account_events"`
+}
+
+// PostLikes is the like count received by one of the user's posts. Likes are
+// only stored as counters, so there is no record of who liked what.
+type PostLikes struct {
+	PostID uint64 `json:"post_id"`
+	Title  string `json:"title"`
+	Likes  uint64 `json:"likes"`
+}
+
+// Run builds the archive of a pending export and stores it in the blob store.
+// It is meant to run in its own goroutine, logging with the logger of the
+// request that asked for it.
+func Run(log *logger.Logger, exportID uint64) {
+	log = log.With("export_id", exportID)
+
+	db, err := db.Connect()
+	if err != nil {
+		log.Error("building export", "error", err)
+		return
+	}
+	defer db.Close()
+
+	repository := repositories.NewDataExportRepository(db)
+
+	// The Recover middleware is long gone, and a panic here would take the
+	// whole process down.
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Error("building export panicked", "panic", fmt.Sprint(recovered), "stack", string(debug.Stack()))
+			markFailed(log, repository, exportID)
+		}
+	}()
+
+	if err = run(db, repository, exportID); err != nil {
+		log.Error("building export", "error", err)
+		markFailed(log, repository, exportID)
+	}
+}
+
+func markFailed(log *logger.Logger, repository *repositories.DataExports, exportID uint64) {
+	if err := repository.MarkFailed(exportID, "the archive could not be generated"); err != nil {
+		log.Error("marking export as failed", "error", err)
+	}
+}
+
+func run(db *sql.DB, repository *repositories.DataExports, exportID uint64) error {
+	export, err := repository.FindById(exportID)
+	if err != nil {
+		return err
+	}
+
+	if err = repository.MarkRunning(exportID); err != nil {
+		return err
+	}
+
+	archive, err := collect(db, export.UserID)
+	if err != nil {
+		return err
+	}
+
+	content, err := write(archive, export.IncludeHTML)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("exports/%d/%d.zip", export.UserID, export.ID)
+	if err = storage.Current().Put(key, bytes.NewReader(content)); err != nil {
+		return err
+	}
+
+	return repository.MarkReady(exportID, key, config.ExportRetention)
+}
+
+func collect(db *sql.DB, userID uint64) (Archive, error) {
+	users := repositories.NewUserRepository(db)
+	posts := repositories.NewPostRepository(db)
+
+	archive := Archive{GeneratedAt: time.Now()}
+
+	var err error
+	if archive.Profile, err = users.FindById(userID); err != nil {
+		return Archive{}, err
+	}
+	archive.Profile.Password = ""
+
+	if archive.Posts, err = posts.FindByUser(userID); err != nil {
+		return Archive{}, err
+	}
+
+	for _, post := range archive.Posts {
+		archive.Likes = append(archive.Likes, PostLikes{PostID: post.ID, Title: post.Title, Likes: post.Likes})
+	}
+
+	if archive.Followers, err = users.GetFollowers(userID); err != nil {
+		return Archive{}, err
+	}
+
+	if archive.Following, err = users.GetFollowing(userID); err != nil {
+		return Archive{}, err
+	}
+
+	if archive.Events, err = users.FindAccountEvents(userID); err != nil {
+		return Archive{}, err
+	}
+
+	return archive, nil
+}
+
+func write(archive Archive, includeHTML bool) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", archive.Profile},
+		{"posts.json", archive.Posts},
+		{"likes.json", archive.Likes},
+		{"followers.json", archive.Followers},
+		{"following.json", archive.Following},
+		{"account_events.json", archive.Events},
+	}
+	for _, f := range files {
+		file, err := writer.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(f.data); err != nil {
+			return nil, err
+		}
+	}
+
+	if includeHTML {
+		file, err := writer.Create("index.html")
+		if err != nil {
+			return nil, err
+		}
+
+		if err = page.Execute(file, archive); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,40 @@
+package export
+
+import "html/template"
+
+var page = template.Must(template.New("index.html").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Your data - {{.Profile.Nickname}}</title>
+</head>
+<body>
+<h1>Your data</h1>
+<p>Generated at {{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}}</p>
+
+<h2>Profile</h2>
+<dl>
+<dt>Name</dt><dd>{{.Profile.Name}}</dd>
+<dt>Nickname</dt><dd>{{.Profile.Nickname}}</dd>
+<dt>Email</dt><dd>{{.Profile.Email}}</dd>
+<dt>Member since</dt><dd>{{.Profile.CreatedAt.Format "2006-01-02"}}</dd>
+</dl>
+
+<h2>Posts ({{len .Posts}})</h2>
+{{range .Posts}}<article>
+<h3>{{.Title}}</h3>
+<p>{{.Content}}</p>
+<p><small>{{.CreatedAt.Format "2006-01-02 15:04"}} &middot; {{.Likes}} likes</small></p>
+</article>
+{{end}}
+<h2>Followers ({{len .Followers}})</h2>
+<ul>{{range .Followers}}<li>{{.Nickname}}</li>{{end}}</ul>
+
+<h2>Following ({{len .Following}})</h2>
+<ul>{{range .Following}}<li>{{.Nickname}}</li>{{end}}</ul>
+
+<h2>Account events</h2>
+<ul>{{range .Events}}<li>{{.OccurredAt.Format "2006-01-02 15:04"}} &ndash; {{.Type}}</li>{{end}}</ul>
+</body>
+</html>
+`))
@@ -0,0 +1,97 @@
+// Package idempotency lets clients retry unsafe requests safely. The first
+// request sent with an Idempotency-Key claims the key, and its response is
+// stored so that retries get it replayed instead of running again.
+package idempotency
+
+import (
+	"api/src/config"
+	"api/src/db"
+	"api/src/security"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Header is the request header carrying the key chosen by the client.
+const Header = "Idempotency-Key"
+
+// MaxKeyLength bounds the keys clients can choose.
+const MaxKeyLength = 255
+
+// Response is a stored response, replayed to retries.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Record is the state of a claimed key.
+type Record struct {
+	// RequestHash identifies the request that claimed the key, so the key
+	// can't be reused for another request.
+	RequestHash string
+	// Completed is false while the first request is still running.
+	Completed bool
+	Response  Response
+}
+
+// Store keeps the claimed keys until they expire.
+type Store interface {
+	// Begin claims key for the request identified by requestHash until ttl
+	// is over. When the key is already claimed, it returns the existing
+	// record and false. A claim left unfinished for lock, e.g. by a crash,
+	// is taken over.
+	Begin(key, requestHash string, lock, ttl time.Duration) (Record, bool, error)
+	// Complete stores the response of the request that claimed key.
+	Complete(key string, response Response) error
+	// Release gives up a claim whose request failed, so it can be retried.
+	Release(key string) error
+	// RemoveExpired forgets the keys whose ttl is over.
+	RemoveExpired() (int64, error)
+}
+
+var current Store = NewMemoryStore()
+
+// Configure picks the store named by config.
+func Configure() error {
+	switch config.IdempotencyStore {
+	case "memory":
+		current = NewMemoryStore()
+	case "sql":
+		current = NewSQLStore(db.Connect)
+	default:
+		return fmt.Errorf("unknown idempotency store %q", config.IdempotencyStore)
+	}
+
+	return nil
+}
+
+func Current() Store {
+	return current
+}
+
+// Key scopes the key chosen by a client, e.g. to its user, so clients can't
+// see each other's responses by guessing keys.
+func Key(scope, key string) string {
+	return security.HashToken(scope + "\x00" + key)
+}
+
+// HashRequest identifies a request by what it asks for: the route template,
+// e.g. "/posts/{postId}", with its parameters and the API version, rather
+// than the path, so a retry through another alias of the route matches.
+func HashRequest(method, route string, params map[string]string, version int, body []byte) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	request := method + " " + route + "\x00" + strconv.Itoa(version)
+	for _, name := range names {
+		request += "\x00" + name + "=" + params[name]
+	}
+
+	return security.HashToken(request + "\x00" + strconv.Itoa(len(body)) + "\x00" + string(body))
+}
@@ -0,0 +1,75 @@
+package idempotency
+
+import (
+	"sync"
+	"time"
+)
+
+type memoryRecord struct {
+	Record
+	lockedUntil time.Time
+	expiresAt   time.Time
+}
+
+// MemoryStore keeps the keys in the process, for a single instance.
+type MemoryStore struct {
+	mutex   sync.Mutex
+	records map[string]memoryRecord
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: map[string]memoryRecord{}}
+}
+
+func (store *MemoryStore) Begin(key, requestHash string, lock, ttl time.Duration) (Record, bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := time.Now()
+	if record, ok := store.records[key]; ok && record.expiresAt.After(now) && (record.Completed || record.lockedUntil.After(now)) {
+		return record.Record, false, nil
+	}
+
+	store.records[key] = memoryRecord{Record: Record{RequestHash: requestHash}, lockedUntil: now.Add(lock), expiresAt: now.Add(ttl)}
+	return Record{}, true, nil
+}
+
+func (store *MemoryStore) Complete(key string, response Response) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	record, ok := store.records[key]
+	if !ok {
+		return nil
+	}
+
+	record.Completed = true
+	record.Response = response
+	store.records[key] = record
+
+	return nil
+}
+
+func (store *MemoryStore) Release(key string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	delete(store.records, key)
+	return nil
+}
+
+func (store *MemoryStore) RemoveExpired() (int64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	var removed int64
+	now := time.Now()
+	for key, record := range store.records {
+		if !record.expiresAt.After(now) {
+			delete(store.records, key)
+			removed++
+		}
+	}
+
+	return removed, nil
+}
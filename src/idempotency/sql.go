@@ -0,0 +1,137 @@
+package idempotency
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// SQLStore keeps the keys in the idempotency_keys table, so retries are
+// recognized by every instance of the API.
+type SQLStore struct {
+	connect func() (*sql.DB, error)
+}
+
+func NewSQLStore(connect func() (*sql.DB, error)) *SQLStore {
+	return &SQLStore{connect: connect}
+}
+
+func (store *SQLStore) Begin(key, requestHash string, lock, ttl time.Duration) (Record, bool, error) {
+	db, err := store.connect()
+	if err != nil {
+		return Record{}, false, err
+	}
+	defer db.Close()
+
+	if _, err = db.Exec("DELETE FROM idempotency_keys WHERE key_hash = ? AND expires_at <= NOW()", key); err != nil {
+		return Record{}, false, err
+	}
+
+	// A claim whose lock is over was abandoned. Of concurrent retries, only
+	// one updates the row.
+	result, err := db.Exec(`
+		UPDATE idempotency_keys
+		SET request_hash = ?, locked_until = DATE_ADD(NOW(), INTERVAL ? SECOND), expires_at = DATE_ADD(NOW(), INTERVAL ? SECOND)
+		WHERE key_hash = ? AND status IS NULL AND locked_until <= NOW()`,
+		requestHash, int64(lock.Seconds()), int64(ttl.Seconds()), key,
+	)
+	if err != nil {
+		return Record{}, false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return Record{}, false, err
+	}
+
+	if affected > 0 {
+		return Record{}, true, nil
+	}
+
+	// The primary key makes the claim atomic: of concurrent requests, only
+	// one inserts the row.
+	result, err = db.Exec(
+		"INSERT IGNORE INTO idempotency_keys (key_hash, request_hash, locked_until, expires_at) VALUES (?, ?, DATE_ADD(NOW(), INTERVAL ? SECOND), DATE_ADD(NOW(), INTERVAL ? SECOND))",
+		key, requestHash, int64(lock.Seconds()), int64(ttl.Seconds()),
+	)
+	if err != nil {
+		return Record{}, false, err
+	}
+
+	if affected, err = result.RowsAffected(); err != nil {
+		return Record{}, false, err
+	}
+
+	if affected > 0 {
+		return Record{}, true, nil
+	}
+
+	var record Record
+	var status sql.NullInt64
+	var header, body []byte
+	err = db.QueryRow(
+		"SELECT request_hash, status, headers, body FROM idempotency_keys WHERE key_hash = ?", key,
+	).Scan(&record.RequestHash, &status, &header, &body)
+	if err == sql.ErrNoRows {
+		// The claim was released in the meantime.
+		return store.Begin(key, requestHash, lock, ttl)
+	}
+	if err != nil {
+		return Record{}, false, err
+	}
+
+	if status.Valid {
+		record.Completed = true
+		record.Response = Response{Status: int(status.Int64), Body: body}
+		if err = json.Unmarshal(header, &record.Response.Header); err != nil {
+			return Record{}, false, err
+		}
+	}
+
+	return record, false, nil
+}
+
+func (store *SQLStore) Complete(key string, response Response) error {
+	header, err := json.Marshal(response.Header)
+	if err != nil {
+		return err
+	}
+
+	db, err := store.connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec(
+		"UPDATE idempotency_keys SET status = ?, headers = ?, body = ? WHERE key_hash = ?",
+		response.Status, header, response.Body, key,
+	)
+	return err
+}
+
+func (store *SQLStore) Release(key string) error {
+	db, err := store.connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec("DELETE FROM idempotency_keys WHERE key_hash = ? AND status IS NULL", key)
+	return err
+}
+
+func (store *SQLStore) RemoveExpired() (int64, error) {
+	db, err := store.connect()
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	result, err := db.Exec("DELETE FROM idempotency_keys WHERE expires_at <= NOW()")
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
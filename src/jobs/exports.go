@@ -0,0 +1,50 @@
+package jobs
+
+import (
+	"api/src/db"
+	"api/src/logger"
+	"api/src/repositories"
+	"api/src/storage"
+	"time"
+)
+
+// RemoveExpiredExports deletes, every interval, the export archives whose
+// retention period is over.
+func RemoveExpiredExports(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := removeExpiredExports(); err != nil {
+			logger.Default().Error("removing expired exports", "job", "exports", "error", err)
+		}
+		<-ticker.C
+	}
+}
+
+func removeExpiredExports() error {
+	db, err := db.Connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	repository := repositories.NewDataExportRepository(db)
+	exports, err := repository.FindExpired()
+	if err != nil {
+		return err
+	}
+
+	for _, export := range exports {
+		if err = storage.Current().Delete(export.BlobKey); err != nil {
+			logger.Default().Error("removing export archive", "job", "exports", "export_id", export.ID, "error", err)
+			continue
+		}
+
+		if err = repository.MarkExpired(export.ID); err != nil {
+			logger.Default().Error("expiring export", "job", "exports", "export_id", export.ID, "error", err)
+		}
+	}
+
+	return nil
+}
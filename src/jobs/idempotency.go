@@ -0,0 +1,24 @@
+package jobs
+
+import (
+	"api/src/idempotency"
+	"api/src/logger"
+	"time"
+)
+
+// RemoveExpiredIdempotencyKeys forgets, every interval, the idempotency keys
+// whose retention period is over.
+func RemoveExpiredIdempotencyKeys(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		removed, err := idempotency.Current().RemoveExpired()
+		if err != nil {
+			logger.Default().Error("removing expired idempotency keys", "job", "idempotency", "error", err)
+		} else if removed > 0 {
+			logger.Default().Info("expired idempotency keys removed", "job", "idempotency", "count", removed)
+		}
+		<-ticker.C
+	}
+}
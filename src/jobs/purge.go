@@ -0,0 +1,57 @@
+package jobs
+
+import (
+	"api/src/config"
+	"api/src/db"
+	"api/src/logger"
+	"api/src/repositories"
+	"time"
+)
+
+// PurgeDeletedUsers permanently removes, every interval, the accounts whose
+// deletion grace period is over. Each removal is logged for compliance.
+func PurgeDeletedUsers(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := purgeDeletedUsers(); err != nil {
+			logger.Default().Error("purging deleted users", "job", "purge", "error", err)
+		}
+		<-ticker.C
+	}
+}
+
+func purgeDeletedUsers() error {
+	db, err := db.Connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	repository := repositories.NewUserRepository(db)
+	users, err := repository.FindExpiredDeletions(config.DeletionGracePeriod)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		purge, err := repository.Purge(user.ID)
+		if err != nil {
+			logger.Default().Error("purging user", "job", "purge", "user_id", user.ID, "error", err)
+			continue
+		}
+
+		logger.Default().Info("user purged",
+			"job", "purge",
+			"user_id", purge.UserID,
+			"deleted_at", purge.DeletedAt.Format(time.RFC3339),
+			"purged_at", purge.PurgedAt.Format(time.RFC3339),
+			"posts", purge.Posts,
+			"followers", purge.Followers,
+			"following", purge.Following,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"api/src/db"
+	"api/src/logger"
+	"api/src/repositories"
+	"time"
+)
+
+// RemoveExpiredSessions deletes, every interval, the sessions whose token
+// has expired.
+func RemoveExpiredSessions(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		removed, err := removeExpiredSessions()
+		if err != nil {
+			logger.Default().Error("removing expired sessions", "job", "sessions", "error", err)
+		} else if removed > 0 {
+			logger.Default().Info("expired sessions removed", "job", "sessions", "count", removed)
+		}
+		<-ticker.C
+	}
+}
+
+func removeExpiredSessions() (int64, error) {
+	db, err := db.Connect()
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	return repositories.NewSessionRepository(db).DeleteExpired()
+}
@@ -0,0 +1,159 @@
+package lockout
+
+import (
+	"api/src/clientip"
+	"api/src/config"
+	"api/src/db"
+	"fmt"
+	"time"
+)
+
+// Record is the failed login state of one key, an account or an IP.
+type Record struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// Store keeps the failed attempt counters.
+type Store interface {
+	Get(key string) (Record, error)
+	// Attempt counts an attempt as a failure, starting over when the
+	// previous one is older than window, unless wait returns how long the
+	// current record must still wait. Both happen atomically, so parallel
+	// attempts see each other.
+	Attempt(key string, window time.Duration, wait func(Record) time.Duration) (time.Duration, error)
+	// Forgive takes back one failure.
+	Forgive(key string) error
+	Lock(key string, until time.Time) error
+	Reset(key string) error
+}
+
+type Policy struct {
+	MaxFailures int
+	Lockout     time.Duration
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Window      time.Duration
+}
+
+// Guard applies a policy to the keys kept in a store.
+type Guard struct {
+	store  Store
+	policy Policy
+}
+
+func NewGuard(store Store, policy Policy) *Guard {
+	return &Guard{store: store, policy: policy}
+}
+
+// Attempt reserves an attempt at key before the credentials are checked, or
+// returns how long key must wait first. The reserved attempt counts as a
+// failure until Reset or Forgive, so parallel guesses can't all get through
+// the same opening of the backoff.
+func (guard *Guard) Attempt(key string) (time.Duration, error) {
+	return guard.store.Attempt(key, guard.policy.Window, guard.retryAfter)
+}
+
+// Fail locks key out once its failed attempts reach the maximum of the
+// policy.
+func (guard *Guard) Fail(key string) error {
+	record, err := guard.store.Get(key)
+	if err != nil {
+		return err
+	}
+
+	if record.Failures >= guard.policy.MaxFailures {
+		return guard.store.Lock(key, time.Now().Add(guard.policy.Lockout))
+	}
+
+	return nil
+}
+
+// Forgive takes back the attempt reserved for key after it succeeded, for
+// keys shared by several accounts, such as IPs, which a success mustn't
+// reset.
+func (guard *Guard) Forgive(key string) error {
+	return guard.store.Forgive(key)
+}
+
+func (guard *Guard) Reset(key string) error {
+	return guard.store.Reset(key)
+}
+
+// retryAfter returns how long the key of record must wait before its next
+// attempt, or 0 if it can try now.
+func (guard *Guard) retryAfter(record Record) time.Duration {
+	now := time.Now()
+	if record.LockedUntil.After(now) {
+		return record.LockedUntil.Sub(now)
+	}
+
+	if record.Failures == 0 || now.Sub(record.LastFailure) > guard.policy.Window {
+		return 0
+	}
+
+	if next := record.LastFailure.Add(guard.delay(record.Failures)); next.After(now) {
+		return next.Sub(now)
+	}
+
+	return 0
+}
+
+// delay doubles with every failure, from BaseDelay up to MaxDelay.
+func (guard *Guard) delay(failures int) time.Duration {
+	delay := guard.policy.BaseDelay
+	for i := 1; i < failures && delay < guard.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > guard.policy.MaxDelay {
+		return guard.policy.MaxDelay
+	}
+	return delay
+}
+
+var (
+	Accounts *Guard
+	IPs      *Guard
+)
+
+// Configure builds the account and IP guards from config.
+func Configure() error {
+	var store Store
+	switch config.LoginAttemptStore {
+	case "memory":
+		store = NewMemoryStore()
+	case "sql":
+		store = NewSQLStore(db.Connect)
+	default:
+		return fmt.Errorf("unknown login attempt store %q", config.LoginAttemptStore)
+	}
+
+	Accounts = NewGuard(store, Policy{
+		MaxFailures: config.LoginMaxFailures,
+		Lockout:     config.LoginLockout,
+		BaseDelay:   config.LoginBackoffBase,
+		MaxDelay:    config.LoginBackoffMax,
+		Window:      config.LoginFailureWindow,
+	})
+	IPs = NewGuard(store, Policy{
+		MaxFailures: config.LoginIPMaxFailures,
+		Lockout:     config.LoginLockout,
+		BaseDelay:   config.LoginBackoffBase,
+		MaxDelay:    config.LoginBackoffMax,
+		Window:      config.LoginFailureWindow,
+	})
+
+	return nil
+}
+
+func AccountKey(email string) string {
+	return "account:" + email
+}
+
+// IPKey normalizes ip, so the login and the admin unlock agree on the key of
+// every spelling of an address.
+func IPKey(ip string) string {
+	return "ip:" + clientip.Normalize(ip)
+}
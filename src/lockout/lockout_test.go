@@ -0,0 +1,76 @@
+package lockout
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParallelAttemptsShareTheBackoff(t *testing.T) {
+	guard := NewGuard(NewMemoryStore(), Policy{
+		MaxFailures: 5,
+		Lockout:     time.Minute,
+		BaseDelay:   time.Minute,
+		MaxDelay:    time.Minute,
+		Window:      time.Hour,
+	})
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			retryAfter, err := guard.Attempt("account:john@example.com")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if retryAfter == 0 {
+				mutex.Lock()
+				allowed++
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 1 {
+		t.Errorf("%d parallel attempts got through, want 1", allowed)
+	}
+}
+
+func TestForgiveTakesBackAnAttempt(t *testing.T) {
+	guard := NewGuard(NewMemoryStore(), Policy{
+		MaxFailures: 1,
+		Lockout:     time.Minute,
+		BaseDelay:   time.Minute,
+		MaxDelay:    time.Minute,
+		Window:      time.Hour,
+	})
+
+	for i := 0; i < 3; i++ {
+		retryAfter, err := guard.Attempt("ip:203.0.113.7")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if retryAfter > 0 {
+			t.Fatalf("attempt %d must wait %v after successful ones", i+1, retryAfter)
+		}
+
+		if err = guard.Forgive("ip:203.0.113.7"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestIPKeyNormalizes(t *testing.T) {
+	if IPKey("::ffff:203.0.113.7") != IPKey("203.0.113.7") {
+		t.Errorf("%q and %q key different records", IPKey("::ffff:203.0.113.7"), IPKey("203.0.113.7"))
+	}
+	if IPKey("2001:DB8::0:1") != IPKey("2001:db8::1") {
+		t.Errorf("%q and %q key different records", IPKey("2001:DB8::0:1"), IPKey("2001:db8::1"))
+	}
+}
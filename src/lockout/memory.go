@@ -0,0 +1,108 @@
+package lockout
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the memory store forgets keys whose failure
+// window and lock are both over.
+const sweepInterval = time.Minute
+
+type MemoryStore struct {
+	mutex     sync.Mutex
+	records   map[string]Record
+	lifetimes map[string]time.Time
+	swept     time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: map[string]Record{}, lifetimes: map[string]time.Time{}}
+}
+
+func (store *MemoryStore) Get(key string) (Record, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return store.records[key], nil
+}
+
+func (store *MemoryStore) Attempt(key string, window time.Duration, wait func(Record) time.Duration) (time.Duration, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := time.Now()
+	store.sweep(now)
+
+	record := store.records[key]
+	if retryAfter := wait(record); retryAfter > 0 {
+		return retryAfter, nil
+	}
+
+	if now.Sub(record.LastFailure) > window {
+		record.Failures = 0
+	}
+
+	record.Failures++
+	record.LastFailure = now
+	store.records[key] = record
+	store.extend(key, now.Add(window))
+
+	return 0, nil
+}
+
+func (store *MemoryStore) Forgive(key string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if record, ok := store.records[key]; ok && record.Failures > 0 {
+		record.Failures--
+		store.records[key] = record
+	}
+
+	return nil
+}
+
+func (store *MemoryStore) Lock(key string, until time.Time) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	record := store.records[key]
+	record.LockedUntil = until
+	store.records[key] = record
+	store.extend(key, until)
+
+	return nil
+}
+
+func (store *MemoryStore) Reset(key string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	delete(store.records, key)
+	delete(store.lifetimes, key)
+	return nil
+}
+
+// extend keeps the record of key at least until expiry.
+func (store *MemoryStore) extend(key string, expiry time.Time) {
+	if expiry.After(store.lifetimes[key]) {
+		store.lifetimes[key] = expiry
+	}
+}
+
+// sweep drops the records whose failures no longer count and whose lock is
+// over, which only failed logins would otherwise leave behind forever.
+func (store *MemoryStore) sweep(now time.Time) {
+	if now.Sub(store.swept) < sweepInterval {
+		return
+	}
+	store.swept = now
+
+	for key, expiry := range store.lifetimes {
+		if now.After(expiry) {
+			delete(store.records, key)
+			delete(store.lifetimes, key)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package lockout
+
+import (
+	"database/sql"
+	"time"
+)
+
+// SQLStore keeps the counters in the login_attempts table, so they are shared
+// by every instance of the API.
+type SQLStore struct {
+	connect func() (*sql.DB, error)
+}
+
+func NewSQLStore(connect func() (*sql.DB, error)) *SQLStore {
+	return &SQLStore{connect: connect}
+}
+
+func (store *SQLStore) Get(key string) (Record, error) {
+	db, err := store.connect()
+	if err != nil {
+		return Record{}, err
+	}
+	defer db.Close()
+
+	return get(db, key)
+}
+
+func (store *SQLStore) Attempt(key string, window time.Duration, wait func(Record) time.Duration) (time.Duration, error) {
+	db, err := store.connect()
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	// The row must exist to be locked while it is checked.
+	now := time.Now()
+	if _, err = db.Exec(
+		"INSERT IGNORE INTO login_attempts (attempt_key, failures, last_failure) VALUES (?, 0, ?)", key, now,
+	); err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	record, err := scanRecord(tx.QueryRow(
+		"SELECT failures, last_failure, locked_until FROM login_attempts WHERE attempt_key = ? FOR UPDATE", key,
+	))
+	if err != nil {
+		return 0, err
+	}
+
+	if retryAfter := wait(record); retryAfter > 0 {
+		return retryAfter, nil
+	}
+
+	if _, err = tx.Exec(
+		"UPDATE login_attempts SET failures = IF(last_failure < ?, 1, failures + 1), last_failure = ? WHERE attempt_key = ?",
+		now.Add(-window), now, key,
+	); err != nil {
+		return 0, err
+	}
+
+	return 0, tx.Commit()
+}
+
+func (store *SQLStore) Forgive(key string) error {
+	db, err := store.connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec("UPDATE login_attempts SET failures = GREATEST(failures - 1, 0) WHERE attempt_key = ?", key)
+	return err
+}
+
+func (store *SQLStore) Lock(key string, until time.Time) error {
+	db, err := store.connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec("UPDATE login_attempts SET locked_until = ? WHERE attempt_key = ?", until, key)
+	return err
+}
+
+func (store *SQLStore) Reset(key string) error {
+	db, err := store.connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec("DELETE FROM login_attempts WHERE attempt_key = ?", key)
+	return err
+}
+
+func get(db *sql.DB, key string) (Record, error) {
+	return scanRecord(db.QueryRow(
+		"SELECT failures, last_failure, locked_until FROM login_attempts WHERE attempt_key = ?", key,
+	))
+}
+
+func scanRecord(row *sql.Row) (Record, error) {
+	var record Record
+	var lockedUntil sql.NullTime
+
+	err := row.Scan(&record.Failures, &record.LastFailure, &lockedUntil)
+	if err == sql.ErrNoRows {
+		return Record{}, nil
+	}
+	if err != nil {
+		return Record{}, err
+	}
+
+	record.LockedUntil = lockedUntil.Time
+	return record, nil
+}
@@ -0,0 +1,215 @@
+// Package logger writes leveled, structured log lines as JSON or as
+// key=value text. Request handlers get a logger carrying the request's
+// correlation fields from the request context.
+package logger
+
+import (
+	"api/src/config"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (level Level) String() string {
+	switch level {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	default:
+		return "error"
+	}
+}
+
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+
+	return LevelInfo, fmt.Errorf("unknown log level %q", name)
+}
+
+// output is shared by a logger and everything derived from it with With.
+type output struct {
+	mu     sync.Mutex
+	writer io.Writer
+	level  Level
+	json   bool
+}
+
+type Logger struct {
+	output *output
+	fields []interface{}
+}
+
+// New creates a logger writing lines of the given format, "json" or "text",
+// at or above level.
+func New(writer io.Writer, level Level, format string) (*Logger, error) {
+	switch format {
+	case "json", "text":
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+
+	return &Logger{output: &output{writer: writer, level: level, json: format == "json"}}, nil
+}
+
+var current = &Logger{output: &output{writer: os.Stderr, level: LevelInfo}}
+
+// Configure replaces the default logger with one following config.
+func Configure() error {
+	level, err := ParseLevel(config.LogLevel)
+	if err != nil {
+		return err
+	}
+
+	logger, err := New(os.Stderr, level, config.LogFormat)
+	if err != nil {
+		return err
+	}
+
+	current = logger
+	return nil
+}
+
+// Default returns the logger used outside of requests, e.g. by jobs.
+func Default() *Logger {
+	return current
+}
+
+// With returns a logger adding the given key/value pairs to every line.
+func (logger *Logger) With(keysAndValues ...interface{}) *Logger {
+	fields := make([]interface{}, 0, len(logger.fields)+len(keysAndValues))
+	fields = append(fields, logger.fields...)
+	fields = append(fields, keysAndValues...)
+
+	return &Logger{output: logger.output, fields: fields}
+}
+
+func (logger *Logger) Debug(message string, keysAndValues ...interface{}) {
+	logger.log(LevelDebug, message, keysAndValues)
+}
+
+func (logger *Logger) Info(message string, keysAndValues ...interface{}) {
+	logger.log(LevelInfo, message, keysAndValues)
+}
+
+func (logger *Logger) Warn(message string, keysAndValues ...interface{}) {
+	logger.log(LevelWarn, message, keysAndValues)
+}
+
+func (logger *Logger) Error(message string, keysAndValues ...interface{}) {
+	logger.log(LevelError, message, keysAndValues)
+}
+
+func (logger *Logger) log(level Level, message string, keysAndValues []interface{}) {
+	if level < logger.output.level {
+		return
+	}
+
+	fields := []interface{}{
+		"time", time.Now().UTC().Format(time.RFC3339Nano),
+		"level", level.String(),
+		"msg", message,
+	}
+	fields = append(fields, logger.fields...)
+	fields = append(fields, keysAndValues...)
+
+	var line bytes.Buffer
+	if logger.output.json {
+		writeJSON(&line, fields)
+	} else {
+		writeText(&line, fields)
+	}
+	line.WriteByte('\n')
+
+	logger.output.mu.Lock()
+	defer logger.output.mu.Unlock()
+	logger.output.writer.Write(line.Bytes())
+}
+
+func writeJSON(line *bytes.Buffer, fields []interface{}) {
+	line.WriteByte('{')
+	for i := 0; i < len(fields); i += 2 {
+		if i > 0 {
+			line.WriteByte(',')
+		}
+
+		key, _ := json.Marshal(fieldKey(fields[i]))
+		line.Write(key)
+		line.WriteByte(':')
+
+		value, err := json.Marshal(fieldValue(fields, i+1))
+		if err != nil {
+			value, _ = json.Marshal(fmt.Sprint(fields[i+1]))
+		}
+		line.Write(value)
+	}
+	line.WriteByte('}')
+}
+
+func writeText(line *bytes.Buffer, fields []interface{}) {
+	for i := 0; i < len(fields); i += 2 {
+		if i > 0 {
+			line.WriteByte(' ')
+		}
+
+		line.WriteString(fieldKey(fields[i]))
+		line.WriteByte('=')
+
+		value := fmt.Sprint(fieldValue(fields, i+1))
+		if value == "" || strings.ContainsAny(value, " \"=\t\n") {
+			value = strconv.Quote(value)
+		}
+		line.WriteString(value)
+	}
+}
+
+func fieldKey(key interface{}) string {
+	if key, ok := key.(string); ok {
+		return key
+	}
+
+	return fmt.Sprint(key)
+}
+
+// fieldValue returns the value at i, with errors as their message. A key
+// without a value is logged with "!MISSING".
+func fieldValue(fields []interface{}, i int) interface{} {
+	if i >= len(fields) {
+		return "!MISSING"
+	}
+
+	switch value := fields[i].(type) {
+	case error:
+		return value.Error()
+	case time.Duration:
+		return value.String()
+	default:
+		return value
+	}
+}
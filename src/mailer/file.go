@@ -0,0 +1,31 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileMailer writes every message as an .eml file into a directory, which is
+// useful in development to read the mails without an SMTP server.
+type FileMailer struct {
+	directory string
+	from      string
+}
+
+func NewFileMailer(directory, from string) *FileMailer {
+	return &FileMailer{directory: directory, from: from}
+}
+
+func (mailer *FileMailer) Send(message Message) error {
+	if err := os.MkdirAll(mailer.directory, 0o755); err != nil {
+		return err
+	}
+
+	recipient := strings.NewReplacer("@", "_at_", "/", "_", "\\", "_").Replace(message.To)
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), recipient)
+
+	return os.WriteFile(filepath.Join(mailer.directory, name), format(mailer.from, message), 0o600)
+}
@@ -0,0 +1,63 @@
+package mailer
+
+import (
+	"api/src/config"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(message Message) error
+}
+
+var current Mailer = NewMemoryMailer()
+
+// Configure selects the mailer implementation from config.MailerDriver.
+func Configure() error {
+	switch config.MailerDriver {
+	case "smtp":
+		current = NewSMTPMailer(config.SMTPHost, config.SMTPPort, config.SMTPUser, config.SMTPPassword, config.MailFrom)
+	case "file":
+		current = NewFileMailer(config.MailDropDir, config.MailFrom)
+	case "memory":
+		current = NewMemoryMailer()
+	default:
+		return fmt.Errorf("unknown mailer %q", config.MailerDriver)
+	}
+
+	return nil
+}
+
+// Use replaces the mailer used by Send.
+func Use(mailer Mailer) {
+	current = mailer
+}
+
+func Current() Mailer {
+	return current
+}
+
+func Send(message Message) error {
+	return current.Send(message)
+}
+
+var headerSanitizer = strings.NewReplacer("\r", "", "\n", "")
+
+func format(from string, message Message) []byte {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "From: %s\r\n", headerSanitizer.Replace(from))
+	fmt.Fprintf(&builder, "To: %s\r\n", headerSanitizer.Replace(message.To))
+	fmt.Fprintf(&builder, "Subject: %s\r\n", headerSanitizer.Replace(message.Subject))
+	fmt.Fprintf(&builder, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	builder.WriteString("MIME-Version: 1.0\r\n")
+	builder.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	builder.WriteString(message.Body)
+	return []byte(builder.String())
+}
@@ -0,0 +1,28 @@
+package mailer
+
+import "sync"
+
+// MemoryMailer keeps the sent messages in memory.
+type MemoryMailer struct {
+	mutex    sync.Mutex
+	messages []Message
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (mailer *MemoryMailer) Send(message Message) error {
+	mailer.mutex.Lock()
+	defer mailer.mutex.Unlock()
+
+	mailer.messages = append(mailer.messages, message)
+	return nil
+}
+
+func (mailer *MemoryMailer) Messages() []Message {
+	mailer.mutex.Lock()
+	defer mailer.mutex.Unlock()
+
+	return append([]Message(nil), mailer.messages...)
+}
@@ -0,0 +1,29 @@
+package mailer
+
+import (
+	"fmt"
+	"net/smtp"
+)
+
+type SMTPMailer struct {
+	address string
+	auth    smtp.Auth
+	from    string
+}
+
+func NewSMTPMailer(host string, port int, user, password, from string) *SMTPMailer {
+	var auth smtp.Auth
+	if user != "" {
+		auth = smtp.PlainAuth("", user, password, host)
+	}
+
+	return &SMTPMailer{
+		address: fmt.Sprintf("%s:%d", host, port),
+		auth:    auth,
+		from:    from,
+	}
+}
+
+func (mailer *SMTPMailer) Send(message Message) error {
+	return smtp.SendMail(mailer.address, mailer.auth, mailer.from, []string{message.To}, format(mailer.from, message))
+}
@@ -0,0 +1,43 @@
+package metrics
+
+var (
+	HTTPRequests = NewCounter(
+		"http_requests_total",
+		"HTTP requests answered, by route template, method and status.",
+		"route", "method", "status",
+	)
+	HTTPRequestDuration = NewHistogram(
+		"http_request_duration_seconds",
+		"Time to answer HTTP requests, by route template and method.",
+		DefaultBuckets,
+		"route", "method",
+	)
+
+	DBQueryDuration = NewHistogram(
+		"db_query_duration_seconds",
+		"Time spent in database queries, by repository and method.",
+		DefaultBuckets,
+		"repository", "method",
+	)
+	DBQueryErrors = NewCounter(
+		"db_query_errors_total",
+		"Database queries that failed, by repository and method.",
+		"repository", "method",
+	)
+
+	RateLimited = NewCounter(
+		"rate_limited_requests_total",
+		"Requests denied by a rate limit policy.",
+		"policy",
+	)
+
+	UsersRegistered = NewCounter("users_registered_total", "Accounts created.")
+	PostsCreated    = NewCounter("posts_created_total", "Posts published.")
+	PostLikes       = NewCounter("post_likes_total", "Likes given to posts.")
+	Follows         = NewCounter("follows_total", "Users followed.")
+	LoginFailures   = NewCounter(
+		"login_failures_total",
+		"Failed logins, by reason: invalid_credentials, locked_out or second_factor.",
+		"reason",
+	)
+)
@@ -0,0 +1,141 @@
+// Package metrics keeps counters, gauges and histograms and exposes them in
+// the Prometheus text format.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type collector interface {
+	write(out *bytes.Buffer)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []collector
+)
+
+func register(c collector) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, c)
+}
+
+// Handler serves every registered metric.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		registryMu.Lock()
+		collectors := append([]collector(nil), registry...)
+		registryMu.Unlock()
+
+		var out bytes.Buffer
+		for _, c := range collectors {
+			c.write(&out)
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(out.Bytes())
+	})
+}
+
+// series holds the values of a metric for each combination of label values.
+type series struct {
+	mu     sync.Mutex
+	labels []string
+	values map[string]interface{}
+}
+
+func (s *series) get(labelValues []string, create func() interface{}) interface{} {
+	if len(labelValues) != len(s.labels) {
+		panic(fmt.Sprintf("metrics: got %d label values for labels %v", len(labelValues), s.labels))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, ok := s.values[key]
+	if !ok {
+		value = create()
+		s.values[key] = value
+	}
+
+	return value
+}
+
+// each calls f for every series in a stable order, with its label pairs
+// formatted as {a="1",b="2"}.
+func (s *series) each(f func(labels string, value interface{})) {
+	s.mu.Lock()
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		values[i] = s.values[key]
+	}
+	s.mu.Unlock()
+
+	for i, key := range keys {
+		var labelValues []string
+		if len(s.labels) > 0 {
+			labelValues = strings.Split(key, "\xff")
+		}
+		f(formatLabels(s.labels, labelValues), values[i])
+	}
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + escapeLabel(values[i]) + `"`
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// withLabel adds one more pair to labels formatted by formatLabels.
+func withLabel(labels, name, value string) string {
+	pair := name + `="` + escapeLabel(value) + `"`
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+
+	return labels[:len(labels)-1] + "," + pair + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func writeHeader(out *bytes.Buffer, name, help, kind string) {
+	fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", name, strings.ReplaceAll(help, "\n", " "), name, kind)
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Counter is a value that only goes up, per combination of label values.
+type Counter struct {
+	name   string
+	help   string
+	series series
+}
+
+type counterValue struct {
+	mu    sync.Mutex
+	value float64
+}
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	counter := &Counter{name: name, help: help, series: series{labels: labels, values: map[string]interface{}{}}}
+	if len(labels) == 0 {
+		counter.Add(0)
+	}
+
+	register(counter)
+	return counter
+}
+
+func (counter *Counter) Inc(labelValues ...string) {
+	counter.Add(1, labelValues...)
+}
+
+func (counter *Counter) Add(delta float64, labelValues ...string) {
+	value := counter.series.get(labelValues, func() interface{} { return &counterValue{} }).(*counterValue)
+
+	value.mu.Lock()
+	value.value += delta
+	value.mu.Unlock()
+}
+
+func (counter *Counter) write(out *bytes.Buffer) {
+	writeHeader(out, counter.name, counter.help, "counter")
+	counter.series.each(func(labels string, v interface{}) {
+		value := v.(*counterValue)
+		value.mu.Lock()
+		defer value.mu.Unlock()
+		fmt.Fprintf(out, "%s%s %s\n", counter.name, labels, formatFloat(value.value))
+	})
+}
+
+// GaugeFunc reports the value returned by a function at scrape time.
+type GaugeFunc struct {
+	name     string
+	help     string
+	kind     string
+	function func() float64
+}
+
+func NewGaugeFunc(name, help string, function func() float64) *GaugeFunc {
+	gauge := &GaugeFunc{name: name, help: help, kind: "gauge", function: function}
+	register(gauge)
+	return gauge
+}
+
+// NewCounterFunc is like NewGaugeFunc for values that only go up, such as
+// totals kept by another package.
+func NewCounterFunc(name, help string, function func() float64) *GaugeFunc {
+	gauge := &GaugeFunc{name: name, help: help, kind: "counter", function: function}
+	register(gauge)
+	return gauge
+}
+
+func (gauge *GaugeFunc) write(out *bytes.Buffer) {
+	writeHeader(out, gauge.name, gauge.help, gauge.kind)
+	fmt.Fprintf(out, "%s %s\n", gauge.name, formatFloat(gauge.function()))
+}
+
+// DefaultBuckets suits latencies in seconds, from 5ms to 10s.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram counts observations in cumulative buckets, per combination of
+// label values.
+type Histogram struct {
+	name    string
+	help    string
+	buckets []float64
+	series  series
+}
+
+type histogramValue struct {
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	histogram := &Histogram{name: name, help: help, buckets: buckets, series: series{labels: labels, values: map[string]interface{}{}}}
+	register(histogram)
+	return histogram
+}
+
+func (histogram *Histogram) Observe(observed float64, labelValues ...string) {
+	value := histogram.series.get(labelValues, func() interface{} {
+		return &histogramValue{counts: make([]uint64, len(histogram.buckets))}
+	}).(*histogramValue)
+
+	value.mu.Lock()
+	defer value.mu.Unlock()
+
+	for i, bound := range histogram.buckets {
+		if observed <= bound {
+			value.counts[i]++
+		}
+	}
+	value.count++
+	value.sum += observed
+}
+
+func (histogram *Histogram) write(out *bytes.Buffer) {
+	writeHeader(out, histogram.name, histogram.help, "histogram")
+	histogram.series.each(func(labels string, v interface{}) {
+		value := v.(*histogramValue)
+		value.mu.Lock()
+		defer value.mu.Unlock()
+
+		for i, bound := range histogram.buckets {
+			fmt.Fprintf(out, "%s_bucket%s %d\n", histogram.name, withLabel(labels, "le", formatFloat(bound)), value.counts[i])
+		}
+		fmt.Fprintf(out, "%s_bucket%s %d\n", histogram.name, withLabel(labels, "le", formatFloat(math.Inf(1))), value.count)
+		fmt.Fprintf(out, "%s_sum%s %s\n", histogram.name, labels, formatFloat(value.sum))
+		fmt.Fprintf(out, "%s_count%s %d\n", histogram.name, labels, value.count)
+	})
+}
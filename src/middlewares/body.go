@@ -0,0 +1,14 @@
+package middlewares
+
+import (
+	"api/src/requests"
+	"net/http"
+)
+
+// MaxBodySize limits the request bodies of the route to size bytes, instead
+// of the default limit.
+func MaxBodySize(size int64, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		next(w, r.WithContext(requests.WithMaxBodySize(r.Context(), size)))
+	}
+}
@@ -0,0 +1,159 @@
+package middlewares
+
+import (
+	"api/src/apperrors"
+	"api/src/authentication"
+	"api/src/clientip"
+	"api/src/config"
+	"api/src/idempotency"
+	"api/src/logger"
+	"api/src/requests"
+	"api/src/responses"
+	"api/src/versioning"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	errInvalidIdempotencyKey = apperrors.New(apperrors.KindInvalid, "invalid_idempotency_key",
+		fmt.Sprintf("the %s header must have at most %d characters", idempotency.Header, idempotency.MaxKeyLength))
+	errIdempotencyKeyReused = apperrors.New(apperrors.KindUnprocessable, "idempotency_key_reused",
+		"the idempotency key was already used for a different request")
+	errRequestInProgress = apperrors.New(apperrors.KindConflict, "request_in_progress",
+		"a request with the same idempotency key is still being processed, retry later")
+)
+
+// replayedHeaders are the response headers stored for replays. The others,
+// e.g. request IDs, belong to each attempt.
+var replayedHeaders = []string{"Content-Type", "Location", "ETag"}
+
+// Idempotent stores the response of requests sent with an Idempotency-Key
+// and replays it to retries with the same key. Retries still running get a
+// 409, and a key reused for another request a 422. Server errors aren't
+// stored, so the request can be retried. It must run after Authenticate, so
+// keys are scoped to the user, and after Version. route is the URI template
+// of the route, without version prefix.
+func Idempotent(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotency.Header)
+		if key == "" {
+			next(w, r)
+			return
+		}
+
+		if len(key) > idempotency.MaxKeyLength {
+			responses.Err(w, r, http.StatusBadRequest, errInvalidIdempotencyKey)
+			return
+		}
+
+		body, err := requests.ReadBody(r)
+		if err != nil {
+			responses.Err(w, r, http.StatusBadRequest, err)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		store := idempotency.Current()
+		storeKey := idempotency.Key(idempotencyScope(r), key)
+		requestHash := idempotency.HashRequest(r.Method, route, mux.Vars(r), versioning.FromContext(r.Context()), body)
+
+		record, claimed, err := store.Begin(storeKey, requestHash, config.IdempotencyLockTimeout, config.IdempotencyTTL)
+		if err != nil {
+			responses.Err(w, r, http.StatusServiceUnavailable, err)
+			return
+		}
+
+		if !claimed {
+			switch {
+			case record.RequestHash != requestHash:
+				responses.Err(w, r, http.StatusUnprocessableEntity, errIdempotencyKeyReused)
+			case !record.Completed:
+				responses.Err(w, r, http.StatusConflict, errRequestInProgress)
+			default:
+				replay(w, record.Response)
+			}
+			return
+		}
+
+		log := logger.FromContext(r.Context())
+		recorder := &responseCapture{ResponseWriter: w, status: http.StatusOK}
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			if err := store.Release(storeKey); err != nil {
+				log.Error("releasing idempotency key", "error", err)
+			}
+		}()
+
+		next(recorder, r)
+
+		if recorder.status >= http.StatusInternalServerError {
+			return
+		}
+
+		response := idempotency.Response{Status: recorder.status, Header: http.Header{}, Body: recorder.body.Bytes()}
+		for _, name := range replayedHeaders {
+			if value := w.Header().Get(name); value != "" {
+				response.Header.Set(name, value)
+			}
+		}
+
+		if err := store.Complete(storeKey, response); err != nil {
+			log.Error("storing idempotent response", "error", err)
+			return
+		}
+		completed = true
+	}
+}
+
+func replay(w http.ResponseWriter, response idempotency.Response) {
+	for name, values := range response.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.Header().Set("Content-Length", strconv.Itoa(len(response.Body)))
+	w.WriteHeader(response.Status)
+
+	if _, err := w.Write(response.Body); err != nil {
+		logger.Default().Error("replaying idempotent response", "error", err)
+	}
+}
+
+// idempotencyScope is the user of the request, or its IP for anonymous
+// requests.
+func idempotencyScope(r *http.Request) string {
+	if userID, err := authentication.ExtractUserId(r); err == nil {
+		return "user:" + strconv.FormatUint(userID, 10)
+	}
+
+	return "ip:" + clientip.FromRequest(r)
+}
+
+// responseCapture keeps a copy of the response while writing it.
+type responseCapture struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (capture *responseCapture) WriteHeader(status int) {
+	if !capture.wroteHeader {
+		capture.status = status
+		capture.wroteHeader = true
+	}
+	capture.ResponseWriter.WriteHeader(status)
+}
+
+func (capture *responseCapture) Write(body []byte) (int, error) {
+	capture.wroteHeader = true
+	capture.body.Write(body)
+	return capture.ResponseWriter.Write(body)
+}
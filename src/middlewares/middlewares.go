@@ -2,24 +2,304 @@ package middlewares
 
 import (
 	"api/src/authentication"
+	"api/src/clientip"
+	"api/src/db"
+	"api/src/logger"
+	"api/src/metrics"
+	"api/src/repositories"
 	"api/src/responses"
-	"log"
+	"api/src/security"
+	"api/src/tracing"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
+const requestIDHeader = "X-Request-ID"
+
+type accessLogKey struct{}
+
+// accessLog collects what the access log needs to know from inner
+// middlewares, such as who the user was.
+type accessLog struct {
+	userID uint64
+}
+
+// RequestID reuses the client's X-Request-ID, or generates one, echoes it in
+// the response and adds it to the request logger.
+func RequestID(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+
+		w.Header().Set(requestIDHeader, requestID)
+		requestLogger := logger.FromContext(r.Context()).With("request_id", requestID)
+		next(w, r.WithContext(logger.NewContext(r.Context(), requestLogger)))
+	}
+}
+
+// Logger writes an access log line once the request is answered.
 func Logger(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("\n %s %s %s", r.Method, r.RequestURI, r.Host)
-		next(w, r)
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		entry := &accessLog{}
+
+		next(recorder, r.WithContext(context.WithValue(r.Context(), accessLogKey{}, entry)))
+
+		fields := []interface{}{
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", recorder.status,
+			"bytes", recorder.bytes,
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"ip", clientip.FromRequest(r),
+		}
+		if entry.userID != 0 {
+			fields = append(fields, "user_id", entry.userID)
+		}
+
+		requestLogger := logger.FromContext(r.Context())
+		if recorder.status >= http.StatusInternalServerError {
+			requestLogger.Error("request", fields...)
+			return
+		}
+		requestLogger.Info("request", fields...)
 	}
 }
 
-func Authenticate(next http.HandlerFunc) http.HandlerFunc {
+// Authenticate accepts a JWT, which grants full access, or a personal access
+// token, which must have been granted every scope of the route. Routes without
+// scopes can't be used with personal access tokens.
+func Authenticate(next http.HandlerFunc, scopes ...string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if err := authentication.ValidateToken(r); err != nil {
-			responses.Err(w, http.StatusUnauthorized, err)
+		ctx, span := tracing.Start(r.Context(), "authenticate", tracing.KindInternal)
+		userID, status, err := authenticate(r.WithContext(ctx), scopes)
+		if err != nil {
+			span.SetError(err.Error())
+		}
+		span.End()
+
+		if err != nil {
+			responses.Err(w, r, status, err)
+			return
+		}
+
+		r = r.WithContext(authentication.WithUserID(r.Context(), userID))
+		next(w, authenticated(r, userID))
+	}
+}
+
+// authenticate returns the user of the request's credentials, or the status
+// to answer with.
+func authenticate(r *http.Request, scopes []string) (uint64, int, error) {
+	if token := authentication.ExtractPersonalAccessToken(r); token != "" {
+		return authenticatePersonalAccessToken(r.Context(), token, scopes)
+	}
+
+	if err := authentication.ValidateToken(r); err != nil {
+		return 0, http.StatusUnauthorized, err
+	}
+
+	revoked, err := tokenRevoked(r)
+	if err != nil {
+		return 0, http.StatusInternalServerError, err
+	}
+
+	if revoked {
+		return 0, http.StatusUnauthorized, errors.New("token has been revoked")
+	}
+
+	userID, err := authentication.ExtractUserId(r)
+	if err != nil {
+		return 0, http.StatusUnauthorized, err
+	}
+
+	return userID, 0, nil
+}
+
+// Admin only lets administrators through. It must run after Authenticate.
+func Admin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := authentication.ExtractUserId(r)
+		if err != nil {
+			responses.Err(w, r, http.StatusUnauthorized, err)
+			return
+		}
+
+		db, err := db.ConnectContext(r.Context())
+		if err != nil {
+			responses.Err(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		defer db.Close()
+
+		isAdmin, err := repositories.NewUserRepository(db).IsAdmin(userID)
+		if err != nil {
+			responses.Err(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if !isAdmin {
+			responses.Err(w, r, http.StatusForbidden, errors.New("you need to be an administrator"))
 			return
 		}
 		next(w, r)
 	}
 }
+
+// Trace starts the server span of a request to route, continuing the trace
+// of the client when it sends a traceparent header. The trace ID is added to
+// the request logger and returned in the X-Trace-ID header.
+func Trace(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracing.ContextWithRemote(r.Context(), tracing.Extract(r.Header))
+		ctx, span := tracing.Start(ctx, r.Method+" "+route, tracing.KindServer)
+		defer span.End()
+
+		spanContext := span.SpanContext()
+		w.Header().Set(tracing.TraceIDHeader, spanContext.TraceID.String())
+
+		requestLogger := logger.FromContext(ctx).With(
+			"trace_id", spanContext.TraceID.String(),
+			"span_id", spanContext.SpanID.String(),
+		)
+		ctx = logger.NewContext(ctx, requestLogger)
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(recorder, r.WithContext(ctx))
+
+		span.SetAttributes(
+			tracing.String("http.method", r.Method),
+			tracing.String("http.route", route),
+			tracing.String("http.target", r.URL.Path),
+			tracing.String("http.user_agent", r.UserAgent()),
+			tracing.Int("http.status_code", recorder.status),
+		)
+		if recorder.status >= http.StatusInternalServerError {
+			span.SetError(http.StatusText(recorder.status))
+		}
+	}
+}
+
+// Metrics records the rate and latency of requests to a route. route is the
+// template from the routes table, so every user or post shares one series.
+func Metrics(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next(recorder, r)
+
+		metrics.HTTPRequests.Inc(route, r.Method, strconv.Itoa(recorder.status))
+		metrics.HTTPRequestDuration.Observe(time.Since(start).Seconds(), route, r.Method)
+	}
+}
+
+// authenticated records the user in the access log and in the fields of the
+// request logger.
+func authenticated(r *http.Request, userID uint64) *http.Request {
+	if entry, ok := r.Context().Value(accessLogKey{}).(*accessLog); ok {
+		entry.userID = userID
+	}
+
+	requestLogger := logger.FromContext(r.Context()).With("user_id", userID)
+	return r.WithContext(logger.NewContext(r.Context(), requestLogger))
+}
+
+func authenticatePersonalAccessToken(ctx context.Context, token string, scopes []string) (uint64, int, error) {
+	if len(scopes) == 0 {
+		return 0, http.StatusForbidden, errors.New("this route can't be used with a personal access token")
+	}
+
+	db, err := db.ConnectContext(ctx)
+	if err != nil {
+		return 0, http.StatusInternalServerError, err
+	}
+	defer db.Close()
+
+	repository := repositories.NewPersonalAccessTokenRepository(db)
+	accessToken, err := repository.FindActive(security.HashToken(token))
+	if err != nil {
+		return 0, http.StatusInternalServerError, err
+	}
+
+	if accessToken.ID == 0 {
+		return 0, http.StatusUnauthorized, errors.New("Invalid token")
+	}
+
+	if !accessToken.HasScope(scopes...) {
+		return 0, http.StatusForbidden, fmt.Errorf("the token needs the scopes %s", strings.Join(scopes, ", "))
+	}
+
+	if err = repository.Touch(accessToken.ID); err != nil {
+		return 0, http.StatusInternalServerError, err
+	}
+
+	return accessToken.UserID, 0, nil
+}
+
+// tokenRevoked reports whether the token was issued before the user's token
+// version was bumped, e.g. by a password reset, its session was revoked, or
+// the user no longer exists.
+func tokenRevoked(r *http.Request) (bool, error) {
+	userID, err := authentication.ExtractUserId(r)
+	if err != nil {
+		return true, nil
+	}
+
+	tokenVersion, err := authentication.ExtractTokenVersion(r)
+	if err != nil {
+		return true, nil
+	}
+
+	db, err := db.ConnectContext(r.Context())
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+
+	currentVersion, err := repositories.NewUserRepository(db).GetTokenVersion(userID)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if currentVersion != tokenVersion {
+		return true, nil
+	}
+
+	// Tokens issued before sessions existed aren't bound to one, and expire
+	// on their own.
+	sessionID, err := authentication.ExtractSessionID(r)
+	if err != nil {
+		return true, nil
+	}
+	if sessionID == 0 {
+		return false, nil
+	}
+
+	sessions := repositories.NewSessionRepository(db)
+	active, err := sessions.Active(userID, sessionID)
+	if err != nil {
+		return false, err
+	}
+	if !active {
+		return true, nil
+	}
+
+	if err = sessions.Touch(sessionID); err != nil {
+		return false, err
+	}
+
+	return false, nil
+}
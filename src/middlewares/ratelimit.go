@@ -0,0 +1,71 @@
+package middlewares
+
+import (
+	"api/src/authentication"
+	"api/src/clientip"
+	"api/src/logger"
+	"api/src/metrics"
+	"api/src/ratelimit"
+	"api/src/responses"
+	"api/src/security"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var errRateLimited = errors.New("too many requests, try again later")
+
+// RateLimit applies policy to the client of each request. Policies keyed by
+// user must run after Authenticate. When the store fails, requests are let
+// through rather than taking the API down with it.
+func RateLimit(policy ratelimit.Policy, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, err := ratelimit.Allow(policy, rateLimitKey(policy.KeyBy, r))
+		if err != nil {
+			logger.FromContext(r.Context()).Error("checking rate limit", "policy", policy.Name, "error", err)
+			next(w, r)
+			return
+		}
+
+		header := w.Header()
+		header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Limit, ceilSeconds(policy.Window)))
+		header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+		if !result.Allowed {
+			metrics.RateLimited.Inc(policy.Name)
+			header.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			responses.Err(w, r, http.StatusTooManyRequests, errRateLimited)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// rateLimitKey identifies the client as the policy asks, falling back to its
+// IP when the request carries no credentials. Session tokens count for their
+// user, as every login issues a new one.
+func rateLimitKey(keyBy ratelimit.KeyBy, r *http.Request) string {
+	switch keyBy {
+	case ratelimit.ByToken:
+		if token := authentication.ExtractPersonalAccessToken(r); token != "" {
+			return "token:" + security.HashToken(token)
+		}
+		fallthrough
+	case ratelimit.ByUser:
+		if userID, err := authentication.ExtractUserId(r); err == nil {
+			return "user:" + strconv.FormatUint(userID, 10)
+		}
+	}
+
+	return "ip:" + clientip.FromRequest(r)
+}
+
+func ceilSeconds(duration time.Duration) int {
+	return int(math.Ceil(duration.Seconds()))
+}
@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"api/src/config"
+	"api/src/logger"
+	"api/src/responses"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+)
+
+// Recover turns a panic in the handler into a 500 and logs its stack, so one
+// bad request can't take the server down. In debug mode the panic and stack
+// are also sent to the client.
+func Recover(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+
+			// net/http uses this panic to abort a response on purpose.
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			stack := string(debug.Stack())
+			logger.FromContext(r.Context()).Error("handler panicked",
+				"panic", fmt.Sprint(recovered),
+				"stack", stack,
+			)
+
+			// Part of the response is already out; the client will see it
+			// cut short.
+			if recorder.wroteHeader {
+				return
+			}
+
+			problem := responses.Problem{
+				Status: http.StatusInternalServerError,
+				Detail: "the server failed to handle the request",
+			}
+			if config.Debug {
+				problem.Panic = fmt.Sprint(recovered)
+				problem.Stack = strings.Split(strings.TrimSpace(stack), "\n")
+			}
+
+			responses.WriteProblem(w, r, problem)
+		}()
+
+		next(recorder, r)
+	}
+}
@@ -0,0 +1,40 @@
+package middlewares
+
+import (
+	"api/src/responses"
+	"api/src/versioning"
+	"fmt"
+	"net/http"
+)
+
+// Version serves next with the API version of the route. Unversioned
+// aliases, registered with version 0, serve the version asked for in the
+// Accept header and point at the same path under that version.
+func Version(version int, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		served := version
+		if version == 0 {
+			w.Header().Add("Vary", "Accept")
+
+			negotiated, err := versioning.Negotiate(r.Header.Get("Accept"))
+			if err != nil {
+				responses.Err(w, r, http.StatusNotAcceptable, err)
+				return
+			}
+			served = negotiated
+
+			w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, versioning.Prefix(served)+r.URL.Path))
+		}
+
+		w.Header().Set(versioning.Header, versioning.Name(served))
+		next(w, r.WithContext(versioning.WithVersion(r.Context(), served)))
+	}
+}
+
+// Deprecated announces in every response of the route that it is going away.
+func Deprecated(deprecation versioning.Deprecation, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deprecation.Write(w.Header())
+		next(w, r)
+	}
+}
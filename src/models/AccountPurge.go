@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+type AccountPurge struct {
+	UserID    uint64    `json:"user_id"`
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgedAt  time.Time `json:"purged_at"`
+	Posts     uint64    `json:"posts"`
+	Followers uint64    `json:"followers"`
+	Following uint64    `json:"following"`
+}
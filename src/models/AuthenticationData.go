@@ -1,6 +1,8 @@
 package models
 
 type AuthenticationData struct {
-	ID    string `json:"id"`
-	Token string `json:"token"`
+	ID                string `json:"id"`
+	Token             string `json:"token,omitempty"`
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken    string `json:"challenge_token,omitempty"`
 }
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+const (
+	ExportPending = "pending"
+	ExportRunning = "running"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+	ExportExpired = "expired"
+)
+
+type DataExport struct {
+	ID          uint64     `json:"id"`
+	UserID      uint64     `json:"user_id"`
+	Status      string     `json:"status"`
+	IncludeHTML bool       `json:"include_html"`
+	BlobKey     string     `json:"-"`
+	Error       string     `json:"error,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+type AccountEvent struct {
+	Type       string    `json:"type"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
@@ -4,3 +4,9 @@ type Password struct {
 	New     string `json:"new"`
 	Current string `json:"current"`
 }
+
+type PasswordReset struct {
+	Email    string `json:"email,omitempty"`
+	Token    string `json:"token,omitempty"`
+	Password string `json:"password,omitempty"`
+}
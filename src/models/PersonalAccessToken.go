@@ -0,0 +1,57 @@
+package models
+
+import (
+	"api/src/authentication"
+	"api/src/validation"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type PersonalAccessToken struct {
+	ID            uint64     `json:"id,omitempty"`
+	UserID        uint64     `json:"-"`
+	Name          string     `json:"name,omitempty"`
+	Prefix        string     `json:"prefix,omitempty"`
+	Token         string     `json:"token,omitempty"`
+	Scopes        []string   `json:"scopes,omitempty"`
+	ExpiresInDays int        `json:"expires_in_days,omitempty"`
+	LastUsedAt    *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at,omitempty"`
+}
+
+func (token *PersonalAccessToken) Prepare() error {
+	token.Name = strings.TrimSpace(token.Name)
+
+	v := validation.New().
+		Field("name", token.Name, validation.Required(), validation.MaxLength(100)).
+		Check("scopes", len(token.Scopes) > 0, "required", "at least one scope is required").
+		Check("expires_in_days", token.ExpiresInDays >= 0, "min", "expires_in_days can't be negative")
+
+	for i, scope := range token.Scopes {
+		v.Check(fmt.Sprintf("scopes[%d]", i), authentication.ValidScope(scope), "unknown_scope", fmt.Sprintf("unknown scope %q", scope))
+	}
+
+	return v.Err()
+}
+
+// HasScope reports whether the token was granted every given scope.
+func (token PersonalAccessToken) HasScope(scopes ...string) bool {
+	for _, scope := range scopes {
+		granted := false
+		for _, tokenScope := range token.Scopes {
+			if tokenScope == scope {
+				granted = true
+				break
+			}
+		}
+
+		if !granted {
+			return false
+		}
+	}
+
+	return true
+}
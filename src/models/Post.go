@@ -1,7 +1,7 @@
 package models
 
 import (
-	"errors"
+	"api/src/validation"
 	"strings"
 	"time"
 )
@@ -13,6 +13,7 @@ type Post struct {
 	AuthorID       uint64    `json:"author_id,omitempty"`
 	AuthorNickname string    `json:"author_nickname,omitempty"`
 	Likes          uint64    `json:"likes,omitempty"`
+	Version        uint64    `json:"-"`
 	CreatedAt      time.Time `json:"created_at,omitempty"`
 }
 
@@ -25,17 +26,38 @@ func (post *Post) Prepare() error {
 	return nil
 }
 
-func (post *Post) validate() error {
-	if post.Title == "" {
-		return errors.New("Title is needed")
+// PrepareFields validates and formats only the given fields, as sent by a
+// partial update.
+func (post *Post) PrepareFields(fields []string) error {
+	v := validation.New()
+	for _, field := range fields {
+		post.validateField(v, field)
 	}
 
-	if post.Content == "" {
-		return errors.New("Content is needed")
+	if err := v.Err(); err != nil {
+		return err
 	}
+
+	post.format()
 	return nil
 }
 
+func (post *Post) validateField(v *validation.Validator, field string) {
+	switch field {
+	case "title":
+		v.Field("title", post.Title, validation.Required(), validation.MaxLength(255))
+	case "content":
+		v.Field("content", post.Content, validation.Required(), validation.MaxLength(300))
+	}
+}
+
+func (post *Post) validate() error {
+	v := validation.New()
+	post.validateField(v, "title")
+	post.validateField(v, "content")
+	return v.Err()
+}
+
 func (post *Post) format() {
 	post.Title = strings.TrimSpace(post.Title)
 	post.Content = strings.TrimSpace(post.Content)
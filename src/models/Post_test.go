@@ -0,0 +1,29 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPostPrepareFieldsReportsEveryViolation(t *testing.T) {
+	post := Post{Title: "", Content: strings.Repeat("a", 301)}
+
+	err := post.PrepareFields([]string{"title", "content"})
+
+	want := []string{"title:required", "content:max_length"}
+	if got := violations(t, err); !reflect.DeepEqual(got, want) {
+		t.Errorf("violations = %v, want %v", got, want)
+	}
+}
+
+func TestPostPrepareFieldsFormats(t *testing.T) {
+	post := Post{Title: "  Title  ", Content: " Content "}
+
+	if err := post.PrepareFields([]string{"title", "content"}); err != nil {
+		t.Fatalf("PrepareFields() = %v, want nil", err)
+	}
+	if post.Title != "Title" || post.Content != "Content" {
+		t.Errorf("post = %q %q, want trimmed values", post.Title, post.Content)
+	}
+}
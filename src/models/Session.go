@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+type Session struct {
+	ID         uint64    `json:"id"`
+	UserID     uint64    `json:"-"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	Current    bool      `json:"current"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
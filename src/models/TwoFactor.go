@@ -0,0 +1,11 @@
+package models
+
+type TwoFactor struct {
+	Secret         string   `json:"secret,omitempty"`
+	URI            string   `json:"otpauth_uri,omitempty"`
+	Code           string   `json:"code,omitempty"`
+	RecoveryCode   string   `json:"recovery_code,omitempty"`
+	RecoveryCodes  []string `json:"recovery_codes,omitempty"`
+	Password       string   `json:"password,omitempty"`
+	ChallengeToken string   `json:"challenge_token,omitempty"`
+}
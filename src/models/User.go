@@ -1,23 +1,33 @@
 package models
 
 import (
+	"api/src/passwordpolicy"
 	"api/src/security"
-	"errors"
+	"api/src/validation"
+	"regexp"
 	"strings"
 	"time"
-
-	"github.com/badoux/checkmail"
 )
 
+var nicknamePattern = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
 type User struct {
-	ID        uint64    `json:"id,omitempty"`
-	Name      string    `json:"name,omitempty"`
-	Nickname  string    `json:"nickname,omitempty"`
-	Email     string    `json:"email,omitempty"`
-	Password  string    `json:"password,omitempty"`
-	CreatedAt time.Time `json:"created_at,omitempty"`
+	ID           uint64     `json:"id,omitempty"`
+	Name         string     `json:"name,omitempty"`
+	Nickname     string     `json:"nickname,omitempty"`
+	Email        string     `json:"email,omitempty"`
+	Password     string     `json:"password,omitempty"`
+	VerifiedAt   *time.Time `json:"verified_at,omitempty"`
+	TokenVersion uint64     `json:"-"`
+	DeletedAt    *time.Time `json:"-"`
+	TOTPEnabled  bool       `json:"-"`
+	Version      uint64     `json:"-"`
+	CreatedAt    time.Time  `json:"created_at,omitempty"`
 }
 
+// Prepare validates and formats user for stage: "register" for a signup,
+// "external" for an account created from an identity provider with a
+// generated password, which skips the password policy, or "edition".
 func (user *User) Prepare(stage string) error {
 	if err := user.validate(stage); err != nil {
 		return err
@@ -30,28 +40,50 @@ func (user *User) Prepare(stage string) error {
 	return nil
 }
 
-func (user *User) validate(stage string) error {
-	if user.Name == "" {
-		return errors.New("name is required")
+// PrepareFields validates and formats only the given fields, as sent by a
+// partial update.
+func (user *User) PrepareFields(fields []string) error {
+	v := validation.New()
+	for _, field := range fields {
+		user.validateField(v, field)
 	}
 
-	if user.Nickname == "" {
-		return errors.New("nickname is required")
+	if err := v.Err(); err != nil {
+		return err
 	}
 
-	if user.Email == "" {
-		return errors.New("email is required")
+	return user.format("edition")
+}
+
+func (user *User) validateField(v *validation.Validator, field string) {
+	switch field {
+	case "name":
+		v.Field("name", user.Name, validation.Required(), validation.MaxLength(255))
+	case "nickname":
+		v.Field("nickname", user.Nickname,
+			validation.Required(),
+			validation.MaxLength(255),
+			validation.Matches(nicknamePattern, "letters, digits and underscores"),
+		)
+	case "email":
+		v.Field("email", user.Email, validation.Required(), validation.MaxLength(255), validation.Email())
 	}
+}
 
-	if err := checkmail.ValidateFormat(user.Email); err != nil {
-		return errors.New("invalid email")
+func (user *User) validate(stage string) error {
+	v := validation.New()
+	for _, field := range []string{"name", "nickname", "email"} {
+		user.validateField(v, field)
 	}
 
-	if stage == "register" && user.Password == "" {
-		return errors.New("password is required")
+	if stage == "register" {
+		v.Field("password", user.Password, validation.Required())
+		if user.Password != "" {
+			v.Merge("password", passwordpolicy.Check(user.Password, user.Name, user.Nickname, user.Email))
+		}
 	}
 
-	return nil
+	return v.Err()
 }
 
 func (user *User) format(stage string) error {
@@ -59,7 +91,7 @@ func (user *User) format(stage string) error {
 	user.Nickname = strings.TrimSpace(user.Nickname)
 	user.Email = strings.TrimSpace(user.Email)
 
-	if stage == "register" {
+	if stage == "register" || stage == "external" {
 		hashedPassword, err := security.Hash(user.Password)
 		if err != nil {
 			return err
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"api/src/apperrors"
+	"api/src/config"
+	"api/src/security"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUserPrepareFieldsReportsEveryViolation(t *testing.T) {
+	user := User{Name: " ", Nickname: "john doe", Email: "john"}
+
+	err := user.PrepareFields([]string{"name", "nickname", "email"})
+
+	want := []string{"name:required", "nickname:format", "email:email"}
+	if got := violations(t, err); !reflect.DeepEqual(got, want) {
+		t.Errorf("violations = %v, want %v", got, want)
+	}
+}
+
+func TestUserPrepareFieldsChecksOnlyGivenFields(t *testing.T) {
+	user := User{Name: "  John  ", Email: "not an email"}
+
+	if err := user.PrepareFields([]string{"name"}); err != nil {
+		t.Fatalf("PrepareFields() = %v, want nil", err)
+	}
+	if user.Name != "John" {
+		t.Errorf("name = %q, want it trimmed", user.Name)
+	}
+}
+
+func TestUserPrepareFieldsRejectsLongValues(t *testing.T) {
+	user := User{Name: strings.Repeat("a", 256), Nickname: strings.Repeat("a", 256)}
+
+	err := user.PrepareFields([]string{"name", "nickname"})
+
+	want := []string{"name:max_length", "nickname:max_length"}
+	if got := violations(t, err); !reflect.DeepEqual(got, want) {
+		t.Errorf("violations = %v, want %v", got, want)
+	}
+}
+
+// violations lists the field and rule of each violation in err, e.g.
+// "name:required".
+func violations(t *testing.T, err error) []string {
+	t.Helper()
+
+	var appErr *apperrors.Error
+	if !errors.As(err, &appErr) {
+		t.Fatalf("error = %v, want an application error", err)
+	}
+
+	var got []string
+	for _, field := range appErr.Fields {
+		got = append(got, field.Field+":"+field.Code)
+	}
+	return got
+}
+
+func TestUserPrepareExternalSkipsPasswordPolicy(t *testing.T) {
+	config.PasswordHasher = "bcrypt"
+	config.BcryptCost = 4
+	if err := security.Configure(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A generated password can happen to contain part of the email, which
+	// the policy refuses on signup.
+	generated := "x4Mock9Qz_Lr2Vb8Ns1Kd7Tw3Yh6Gf0Jp5Ce8Ua2Xo"
+	user := User{Name: "Mock User", Nickname: "mock_ab12cd", Email: "mock@example.com", Password: generated}
+
+	signup := user
+	if err := signup.Prepare("register"); err == nil {
+		t.Fatal("Prepare(register) accepted a password containing the email")
+	}
+
+	if err := user.Prepare("external"); err != nil {
+		t.Fatalf("Prepare(external) = %v, want nil", err)
+	}
+	if err := security.VerifyPassword(user.Password, generated); err != nil {
+		t.Errorf("the generated password wasn't hashed: %v", err)
+	}
+}
@@ -0,0 +1,6 @@
+package models
+
+type Verification struct {
+	Token string `json:"token,omitempty"`
+	Email string `json:"email,omitempty"`
+}
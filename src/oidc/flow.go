@@ -0,0 +1,81 @@
+package oidc
+
+import (
+	"api/src/authentication"
+	"api/src/security"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const flowPurpose = "oidc_flow"
+
+// Flow is the state of one authorization-code flow between the redirect to
+// the provider and its callback. It travels in a signed cookie, so no server
+// side storage is needed.
+type Flow struct {
+	Provider     string `json:"provider"`
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	Expires      int64  `json:"expires"`
+}
+
+func NewFlow(provider string, ttl time.Duration) (Flow, error) {
+	flow := Flow{Provider: provider, Expires: time.Now().Add(ttl).Unix()}
+
+	var err error
+	if flow.State, err = security.GenerateToken(); err != nil {
+		return Flow{}, err
+	}
+	if flow.Nonce, err = security.GenerateToken(); err != nil {
+		return Flow{}, err
+	}
+	if flow.CodeVerifier, err = security.GenerateToken(); err != nil {
+		return Flow{}, err
+	}
+
+	return flow, nil
+}
+
+// CodeChallenge is the PKCE S256 challenge of the code verifier.
+func (flow Flow) CodeChallenge() string {
+	sum := sha256.Sum256([]byte(flow.CodeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (flow Flow) Encode() (string, error) {
+	content, err := json.Marshal(flow)
+	if err != nil {
+		return "", err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(content)
+	return payload + "." + authentication.Sign(flowPurpose, payload), nil
+}
+
+func DecodeFlow(value string) (Flow, error) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 2 || !authentication.VerifySignature(flowPurpose, parts[0], parts[1]) {
+		return Flow{}, errors.New("invalid login flow")
+	}
+
+	content, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return Flow{}, err
+	}
+
+	var flow Flow
+	if err = json.Unmarshal(content, &flow); err != nil {
+		return Flow{}, err
+	}
+
+	if time.Now().Unix() > flow.Expires {
+		return Flow{}, errors.New("the login flow has expired")
+	}
+
+	return flow, nil
+}
@@ -0,0 +1,49 @@
+package oidc
+
+import (
+	"api/src/config"
+	"context"
+	"sync"
+)
+
+var (
+	mutex     sync.RWMutex
+	providers = map[string]*Provider{}
+)
+
+// Configure discovers every provider listed in config.OIDCProviders.
+func Configure(ctx context.Context) error {
+	for _, providerConfig := range config.OIDCProviders {
+		provider, err := NewProvider(ctx, ProviderConfig{
+			Name:         providerConfig.Name,
+			Issuer:       providerConfig.Issuer,
+			ClientID:     providerConfig.ClientID,
+			ClientSecret: providerConfig.ClientSecret,
+			RedirectURL:  providerConfig.RedirectURL,
+			Scopes:       providerConfig.Scopes,
+		}, nil)
+		if err != nil {
+			return err
+		}
+
+		Register(provider)
+	}
+
+	return nil
+}
+
+// Register makes a provider available for login under its name.
+func Register(provider *Provider) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	providers[provider.Name()] = provider
+}
+
+func Get(name string) (*Provider, bool) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	provider, ok := providers[name]
+	return provider, ok
+}
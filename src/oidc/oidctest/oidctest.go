@@ -0,0 +1,212 @@
+// Package oidctest runs an in-process OpenID Connect provider, so the login
+// flow can be exercised without a real identity provider.
+package oidctest
+
+import (
+	"api/src/oidc"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	ClientID     = "test-client"
+	ClientSecret = "test-secret"
+	keyID        = "test-key"
+)
+
+// User is the identity the provider logs in on the next authorization.
+type User struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type authorization struct {
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	user          User
+}
+
+type Provider struct {
+	Server *httptest.Server
+	User   User
+
+	key   *rsa.PrivateKey
+	mutex sync.Mutex
+	codes map[string]authorization
+}
+
+// NewProvider starts the mock provider. Close it when done.
+func NewProvider() (*Provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := &Provider{
+		key:   key,
+		codes: map[string]authorization{},
+		User:  User{Subject: "mock-subject", Email: "mock@example.com", EmailVerified: true, Name: "Mock User"},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", provider.discovery)
+	mux.HandleFunc("/authorize", provider.authorize)
+	mux.HandleFunc("/token", provider.token)
+	mux.HandleFunc("/jwks", provider.jwks)
+	provider.Server = httptest.NewServer(mux)
+
+	return provider, nil
+}
+
+func (provider *Provider) Close() {
+	provider.Server.Close()
+}
+
+func (provider *Provider) Issuer() string {
+	return provider.Server.URL
+}
+
+// Relying returns a relying party configured against the mock provider.
+func (provider *Provider) Relying(name, redirectURL string) (*oidc.Provider, error) {
+	return oidc.NewProvider(context.Background(), oidc.ProviderConfig{
+		Name:         name,
+		Issuer:       provider.Issuer(),
+		ClientID:     ClientID,
+		ClientSecret: ClientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"openid", "email", "profile"},
+	}, provider.Server.Client())
+}
+
+func (provider *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 provider.Issuer(),
+		"authorization_endpoint": provider.Issuer() + "/authorize",
+		"token_endpoint":         provider.Issuer() + "/token",
+		"jwks_uri":               provider.Issuer() + "/jwks",
+	})
+}
+
+// authorize logs the configured user in right away and redirects back.
+func (provider *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != ClientID || query.Get("code_challenge_method") != "S256" || query.Get("response_type") != "code" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	code := randomString()
+
+	provider.mutex.Lock()
+	provider.codes[code] = authorization{
+		redirectURI:   query.Get("redirect_uri"),
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+		user:          provider.User,
+	}
+	provider.mutex.Unlock()
+
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	values := redirect.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	redirect.RawQuery = values.Encode()
+
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (provider *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	provider.mutex.Lock()
+	grant, ok := provider.codes[r.PostForm.Get("code")]
+	delete(provider.codes, r.PostForm.Get("code"))
+	provider.mutex.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	switch {
+	case !ok,
+		r.PostForm.Get("client_id") != ClientID,
+		r.PostForm.Get("client_secret") != ClientSecret,
+		r.PostForm.Get("redirect_uri") != grant.redirectURI,
+		base64.RawURLEncoding.EncodeToString(sum[:]) != grant.codeChallenge:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            provider.Issuer(),
+		"aud":            ClientID,
+		"sub":            grant.user.Subject,
+		"email":          grant.user.Email,
+		"email_verified": grant.user.EmailVerified,
+		"name":           grant.user.Name,
+		"nonce":          grant.nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+	})
+	idToken.Header["kid"] = keyID
+
+	signed, err := idToken.SignedString(provider.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     signed,
+	})
+}
+
+func (provider *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	publicKey := provider.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kid": keyID,
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+		}},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func randomString() string {
+	buffer := make([]byte, 16)
+	rand.Read(buffer)
+	return base64.RawURLEncoding.EncodeToString(buffer)
+}
@@ -0,0 +1,314 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+type ProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Identity is what the provider tells us about the user in the ID token.
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider is an OpenID Connect provider we act as a relying party for.
+type Provider struct {
+	config    ProviderConfig
+	client    *http.Client
+	discovery discovery
+
+	mutex sync.Mutex
+	keys  map[string]interface{}
+}
+
+// NewProvider reads the provider metadata from its discovery document.
+func NewProvider(ctx context.Context, config ProviderConfig, client *http.Client) (*Provider, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	provider := &Provider{config: config, client: client}
+
+	wellKnown := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := provider.getJSON(ctx, wellKnown, &provider.discovery); err != nil {
+		return nil, fmt.Errorf("discovering %s: %w", config.Name, err)
+	}
+
+	if provider.discovery.Issuer != config.Issuer {
+		return nil, fmt.Errorf("discovering %s: issuer %q doesn't match %q", config.Name, provider.discovery.Issuer, config.Issuer)
+	}
+
+	return provider, nil
+}
+
+func (provider *Provider) Name() string {
+	return provider.config.Name
+}
+
+// AuthCodeURL is where the user is sent to authenticate with the provider.
+func (provider *Provider) AuthCodeURL(state, nonce, codeChallenge string) string {
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", provider.config.ClientID)
+	query.Set("redirect_uri", provider.config.RedirectURL)
+	query.Set("scope", strings.Join(provider.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(provider.discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return provider.discovery.AuthorizationEndpoint + separator + query.Encode()
+}
+
+// Exchange trades the authorization code for tokens and returns the verified
+// identity from the ID token.
+func (provider *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (Identity, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", provider.config.RedirectURL)
+	form.Set("client_id", provider.config.ClientID)
+	form.Set("client_secret", provider.config.ClientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, provider.discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+
+	response, err := provider.client.Do(request)
+	if err != nil {
+		return Identity{}, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return Identity{}, fmt.Errorf("token endpoint answered %s", response.Status)
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err = json.NewDecoder(response.Body).Decode(&tokens); err != nil {
+		return Identity{}, err
+	}
+
+	if tokens.IDToken == "" {
+		return Identity{}, errors.New("the token response has no id_token")
+	}
+
+	return provider.verifyIDToken(ctx, tokens.IDToken, nonce)
+}
+
+func (provider *Provider) verifyIDToken(ctx context.Context, idToken, nonce string) (Identity, error) {
+	token, err := jwt.Parse(idToken, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		default:
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+
+		kid, _ := token.Header["kid"].(string)
+		return provider.key(ctx, kid)
+	})
+	if err != nil {
+		return Identity{}, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return Identity{}, errors.New("invalid id token")
+	}
+
+	if !claims.VerifyIssuer(provider.config.Issuer, true) {
+		return Identity{}, errors.New("id token issued by another issuer")
+	}
+
+	if !audienceContains(claims["aud"], provider.config.ClientID) {
+		return Identity{}, errors.New("id token issued for another client")
+	}
+
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return Identity{}, errors.New("id token has expired")
+	}
+
+	if claimNonce, _ := claims["nonce"].(string); claimNonce == "" || claimNonce != nonce {
+		return Identity{}, errors.New("id token nonce doesn't match")
+	}
+
+	identity := Identity{Provider: provider.config.Name}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+
+	if identity.Subject == "" {
+		return Identity{}, errors.New("id token has no subject")
+	}
+
+	return identity, nil
+}
+
+func audienceContains(audience interface{}, clientID string) bool {
+	switch value := audience.(type) {
+	case string:
+		return value == clientID
+	case []interface{}:
+		for _, item := range value {
+			if item == clientID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// key returns the signing key with the given id, fetching the provider's key
+// set again when it is unknown, since providers rotate their keys.
+func (provider *Provider) key(ctx context.Context, kid string) (interface{}, error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	if key, ok := provider.keys[kid]; ok {
+		return key, nil
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := provider.getJSON(ctx, provider.discovery.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+
+	provider.keys = map[string]interface{}{}
+	for _, webKey := range set.Keys {
+		key, err := webKey.publicKey()
+		if err != nil {
+			continue
+		}
+		provider.keys[webKey.Kid] = key
+	}
+
+	if key, ok := provider.keys[kid]; ok {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (provider *Provider) getJSON(ctx context.Context, url string, target interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+
+	response, err := provider.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered %s", url, response.Status)
+	}
+
+	return json.NewDecoder(response.Body).Decode(target)
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (webKey jsonWebKey) publicKey() (interface{}, error) {
+	switch webKey.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(webKey.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(webKey.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch webKey.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", webKey.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(webKey.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(webKey.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", webKey.Kty)
+}
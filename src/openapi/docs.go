@@ -0,0 +1,9 @@
+package openapi
+
+import _ "embed"
+
+// DocsPage renders the document served next to it, at openapi.json, without
+// loading anything from elsewhere.
+//
+//go:embed docs.html
+var DocsPage []byte
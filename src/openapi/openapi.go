@@ -0,0 +1,246 @@
+// Package openapi generates the OpenAPI 3.1 document of the API from the
+// route table and the types the handlers read and write, so the document
+// can't drift from the code.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const jsonType = "application/json"
+
+var pathParameter = regexp.MustCompile(`\{(\w+)\}`)
+
+// Info describes the API as a whole.
+type Info struct {
+	Title       string
+	Version     string
+	Description string
+}
+
+// Operation describes one route.
+type Operation struct {
+	Method  string
+	Path    string
+	Summary string
+	Tags    []string
+	// Secured operations need a bearer token, a JWT or a personal access
+	// token, with Scopes.
+	Secured bool
+	Scopes  []string
+	// Query lists the query parameters with their description.
+	Query map[string]string
+	// Idempotent operations accept an Idempotency-Key header.
+	Idempotent bool
+	Deprecated bool
+	// Request and Response are values of the body types, nil when there is
+	// no body. Status is the status of a successful response.
+	Request  interface{}
+	Response interface{}
+	Status   int
+	// ResponseType is the media type of the response when it isn't JSON.
+	ResponseType string
+}
+
+// Document is an OpenAPI document, ready to be encoded as JSON.
+type Document map[string]interface{}
+
+// Build generates the document of operations. It fails when an operation
+// isn't described, so undocumented routes are caught at startup.
+func Build(info Info, operations []Operation, problem interface{}) (Document, error) {
+	var missing []string
+	for _, operation := range operations {
+		if operation.Summary == "" || len(operation.Tags) == 0 || operation.Status == 0 {
+			missing = append(missing, operation.Method+" "+operation.Path)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("routes without a specification, they need a summary, tags and a status: %s", strings.Join(missing, ", "))
+	}
+
+	s := newSchemas()
+	problemSchema := s.of(reflect.TypeOf(problem))
+
+	paths := map[string]interface{}{}
+	for _, operation := range operations {
+		path, ok := paths[operation.Path].(map[string]interface{})
+		if !ok {
+			path = map[string]interface{}{}
+			paths[operation.Path] = path
+		}
+
+		path[strings.ToLower(operation.Method)] = describe(s, operation, problemSchema)
+	}
+
+	return Document{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":       info.Title,
+			"version":     info.Version,
+			"description": info.Description,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": s.components,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "A JWT from /login, or a personal access token.",
+				},
+			},
+		},
+	}, nil
+}
+
+func describe(s *schemas, operation Operation, problemSchema map[string]interface{}) map[string]interface{} {
+	described := map[string]interface{}{
+		"summary":     operation.Summary,
+		"tags":        operation.Tags,
+		"operationId": operationID(operation),
+	}
+
+	if operation.Deprecated {
+		described["deprecated"] = true
+	}
+
+	var parameters []interface{}
+	for _, match := range pathParameter.FindAllStringSubmatch(operation.Path, -1) {
+		schema := map[string]interface{}{"type": "string"}
+		if strings.HasSuffix(match[1], "Id") {
+			schema = map[string]interface{}{"type": "integer", "minimum": 1}
+		}
+		parameters = append(parameters, map[string]interface{}{
+			"name": match[1], "in": "path", "required": true, "schema": schema,
+		})
+	}
+
+	names := make([]string, 0, len(operation.Query))
+	for name := range operation.Query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parameters = append(parameters, map[string]interface{}{
+			"name": name, "in": "query", "description": operation.Query[name], "schema": map[string]interface{}{"type": "string"},
+		})
+	}
+
+	if operation.Idempotent {
+		parameters = append(parameters, map[string]interface{}{
+			"name":        "Idempotency-Key",
+			"in":          "header",
+			"description": "Retries with the same key get the first response replayed instead of running again.",
+			"schema":      map[string]interface{}{"type": "string", "maxLength": 255},
+		})
+	}
+
+	if len(parameters) > 0 {
+		described["parameters"] = parameters
+	}
+
+	if operation.Request != nil {
+		schema := s.of(reflect.TypeOf(operation.Request))
+		content := map[string]interface{}{jsonType: map[string]interface{}{"schema": schema}}
+		if operation.Method == http.MethodPatch {
+			content = map[string]interface{}{
+				"application/merge-patch+json": map[string]interface{}{"schema": schema},
+				"application/json-patch+json":  map[string]interface{}{"schema": jsonPatchSchema},
+			}
+		}
+		described["requestBody"] = map[string]interface{}{"required": true, "content": content}
+	}
+
+	success := map[string]interface{}{"description": http.StatusText(operation.Status)}
+	switch {
+	case operation.ResponseType != "":
+		success["content"] = map[string]interface{}{
+			operation.ResponseType: map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+		}
+	case operation.Response != nil:
+		success["content"] = map[string]interface{}{
+			jsonType: map[string]interface{}{"schema": s.of(reflect.TypeOf(operation.Response))},
+		}
+	}
+
+	described["responses"] = map[string]interface{}{
+		fmt.Sprint(operation.Status): success,
+		"default": map[string]interface{}{
+			"description": "An error, described as an RFC 7807 problem.",
+			"content": map[string]interface{}{
+				"application/problem+json": map[string]interface{}{"schema": problemSchema},
+			},
+		},
+	}
+
+	if operation.Secured {
+		scopes := operation.Scopes
+		if scopes == nil {
+			scopes = []string{}
+		}
+		described["security"] = []interface{}{map[string]interface{}{"bearerAuth": scopes}}
+	}
+
+	return described
+}
+
+// operationID names the operation after its method and path, e.g.
+// "get_users_userId_posts".
+func operationID(operation Operation) string {
+	parts := []string{strings.ToLower(operation.Method)}
+	for _, segment := range strings.Split(operation.Path, "/") {
+		if segment = strings.Trim(segment, "{}"); segment != "" {
+			parts = append(parts, strings.ReplaceAll(segment, "-", "_"))
+		}
+	}
+
+	return strings.Join(parts, "_")
+}
+
+var jsonPatchSchema = map[string]interface{}{
+	"type": "array",
+	"items": map[string]interface{}{
+		"type":     "object",
+		"required": []string{"op", "path"},
+		"properties": map[string]interface{}{
+			"op":    map[string]interface{}{"enum": []string{"add", "remove", "replace", "move", "copy", "test"}},
+			"path":  map[string]interface{}{"type": "string"},
+			"from":  map[string]interface{}{"type": "string"},
+			"value": map[string]interface{}{},
+		},
+	},
+}
+
+var (
+	mu        sync.RWMutex
+	published []byte
+)
+
+// Publish makes document the one served by Current.
+func Publish(document Document) error {
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	published = encoded
+	mu.Unlock()
+
+	return nil
+}
+
+// Current returns the published document, encoded as JSON.
+func Current() json.RawMessage {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return published
+}
@@ -0,0 +1,113 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+var bloomMagic = [8]byte{'P', 'W', 'B', 'L', 'O', 'O', 'M', '1'}
+
+// BloomFilter is a compact set of SHA-1 password hashes. It can answer that a
+// password was breached when it wasn't, at the configured false positive
+// rate, but never the opposite. Keying on SHA-1 lets it be built from the
+// Have I Been Pwned hash lists.
+type BloomFilter struct {
+	bits   []byte
+	size   uint64
+	hashes uint32
+}
+
+// NewBloomFilter sizes a filter for count entries at the given false positive
+// rate.
+func NewBloomFilter(count int, falsePositiveRate float64) *BloomFilter {
+	if count < 1 {
+		count = 1
+	}
+
+	size := uint64(math.Ceil(-float64(count) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	hashes := uint32(math.Max(1, math.Round(float64(size)/float64(count)*math.Ln2)))
+
+	return &BloomFilter{bits: make([]byte, (size+7)/8), size: size, hashes: hashes}
+}
+
+func (filter *BloomFilter) Add(password string) {
+	filter.AddHash(sha1.Sum([]byte(password)))
+}
+
+func (filter *BloomFilter) AddHash(sum [sha1.Size]byte) {
+	for _, index := range filter.indexes(sum) {
+		filter.bits[index/8] |= 1 << (index % 8)
+	}
+}
+
+func (filter *BloomFilter) Contains(password string) bool {
+	for _, index := range filter.indexes(sha1.Sum([]byte(password))) {
+		if filter.bits[index/8]&(1<<(index%8)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// indexes derives the bit positions by double hashing the SHA-1.
+func (filter *BloomFilter) indexes(sum [sha1.Size]byte) []uint64 {
+	first := binary.BigEndian.Uint64(sum[0:8])
+	second := binary.BigEndian.Uint64(sum[8:16]) | 1
+
+	indexes := make([]uint64, filter.hashes)
+	for i := range indexes {
+		indexes[i] = (first + uint64(i)*second) % filter.size
+	}
+
+	return indexes
+}
+
+// WriteTo stores the filter as: magic, bit count, hash count, bits.
+func (filter *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	header := make([]byte, 20)
+	copy(header, bloomMagic[:])
+	binary.BigEndian.PutUint64(header[8:16], filter.size)
+	binary.BigEndian.PutUint32(header[16:20], filter.hashes)
+
+	written, err := w.Write(header)
+	if err != nil {
+		return int64(written), err
+	}
+
+	n, err := w.Write(filter.bits)
+	return int64(written + n), err
+}
+
+func ReadBloomFilter(r io.Reader) (*BloomFilter, error) {
+	reader := bufio.NewReader(r)
+
+	header := make([]byte, 20)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(header[:8], bloomMagic[:]) {
+		return nil, errors.New("not a breached password filter")
+	}
+
+	filter := &BloomFilter{
+		size:   binary.BigEndian.Uint64(header[8:16]),
+		hashes: binary.BigEndian.Uint32(header[16:20]),
+	}
+	if filter.size == 0 || filter.hashes == 0 {
+		return nil, errors.New("corrupted breached password filter")
+	}
+
+	filter.bits = make([]byte, (filter.size+7)/8)
+	if _, err := io.ReadFull(reader, filter.bits); err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
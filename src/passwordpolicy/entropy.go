@@ -0,0 +1,75 @@
+package passwordpolicy
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// commonPatterns are fragments guessers try first. A password containing one
+// gets little credit for those characters.
+var commonPatterns = []string{
+	"password", "passw0rd", "qwerty", "azerty", "asdf", "zxcv", "letmein",
+	"welcome", "admin", "login", "iloveyou", "monkey", "dragon", "master",
+	"1234", "abcd", "senha",
+}
+
+// EstimateEntropy gives a rough estimate, in bits, of how hard the password is
+// to guess. Each character is worth log2 of the size of the character classes
+// used, except repeats and sequences like "aaa" or "123", and common patterns.
+func EstimateEntropy(password string) float64 {
+	runes := []rune(password)
+	if len(runes) == 0 {
+		return 0
+	}
+
+	bitsPerCharacter := math.Log2(float64(poolSize(runes)))
+
+	entropy := 0.0
+	for i, r := range runes {
+		if i > 0 && (r == runes[i-1] || r == runes[i-1]+1 || r == runes[i-1]-1) {
+			entropy++
+			continue
+		}
+		entropy += bitsPerCharacter
+	}
+
+	lowered := strings.ToLower(password)
+	for _, pattern := range commonPatterns {
+		if count := strings.Count(lowered, pattern); count > 0 {
+			entropy -= float64(count) * (float64(len(pattern))*bitsPerCharacter - 4)
+		}
+	}
+
+	return math.Max(entropy, 0)
+}
+
+func poolSize(runes []rune) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII && unicode.IsPrint(r):
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	pool := 0
+	for _, class := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.used {
+			pool += class.size
+		}
+	}
+
+	return pool
+}
@@ -0,0 +1,129 @@
+package passwordpolicy
+
+import (
+	"api/src/apperrors"
+	"api/src/config"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+type Policy struct {
+	MinLength      int
+	MaxLength      int
+	MinEntropyBits float64
+	// Breached holds known leaked passwords; nil disables the check.
+	Breached *BloomFilter
+}
+
+var current = Policy{MinLength: 10, MaxLength: 128, MinEntropyBits: 40}
+
+// Configure builds the policy from config and loads the breached password
+// filter, if one is configured.
+func Configure() error {
+	policy := Policy{
+		MinLength:      config.PasswordMinLength,
+		MaxLength:      config.PasswordMaxLength,
+		MinEntropyBits: config.PasswordMinEntropy,
+	}
+
+	if config.BreachedPasswordsFile != "" {
+		file, err := os.Open(config.BreachedPasswordsFile)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		if policy.Breached, err = ReadBloomFilter(file); err != nil {
+			return fmt.Errorf("reading %s: %w", config.BreachedPasswordsFile, err)
+		}
+	}
+
+	current = policy
+	return nil
+}
+
+// Check validates password with the configured policy. A rejected password
+// gets an error detailing every rule it broke. personal holds the
+// user's name, nickname, email and so on, which the password can't contain.
+func Check(password string, personal ...string) error {
+	return current.Check(password, personal...)
+}
+
+func (policy Policy) Check(password string, personal ...string) error {
+	var violations []apperrors.FieldError
+
+	length := utf8.RuneCountInString(password)
+	if length < policy.MinLength {
+		violations = append(violations, apperrors.FieldError{
+			Field:   "password",
+			Code:    "min_length",
+			Message: fmt.Sprintf("password must have at least %d characters", policy.MinLength),
+		})
+	}
+
+	if policy.MaxLength > 0 && length > policy.MaxLength {
+		violations = append(violations, apperrors.FieldError{
+			Field:   "password",
+			Code:    "max_length",
+			Message: fmt.Sprintf("password must have at most %d characters", policy.MaxLength),
+		})
+	}
+
+	if part, ok := containsPersonalInfo(password, personal); ok {
+		violations = append(violations, apperrors.FieldError{
+			Field:   "password",
+			Code:    "personal_info",
+			Message: fmt.Sprintf("password can't contain your personal information (%q)", part),
+		})
+	}
+
+	if entropy := EstimateEntropy(password); entropy < policy.MinEntropyBits {
+		violations = append(violations, apperrors.FieldError{
+			Field:   "password",
+			Code:    "entropy",
+			Message: "password is too easy to guess, use a longer mix of unrelated words, digits and symbols",
+		})
+	}
+
+	if policy.Breached != nil && policy.Breached.Contains(password) {
+		violations = append(violations, apperrors.FieldError{
+			Field:   "password",
+			Code:    "breached",
+			Message: "password appeared in a data breach, choose another one",
+		})
+	}
+
+	if len(violations) > 0 {
+		return apperrors.New(apperrors.KindInvalid, "weak_password", "password rejected").WithFields(violations...)
+	}
+
+	return nil
+}
+
+// containsPersonalInfo looks for any part of the personal information of at
+// least 3 characters, such as a name or the local part of an email. The
+// domain of an email is shared with too many people to be personal.
+func containsPersonalInfo(password string, personal []string) (string, bool) {
+	lowered := strings.ToLower(password)
+
+	for _, info := range personal {
+		info = strings.ToLower(strings.TrimSpace(info))
+		if at := strings.LastIndex(info, "@"); at >= 0 {
+			info = info[:at]
+		}
+		parts := strings.FieldsFunc(info, func(r rune) bool {
+			return r == ' ' || r == '@' || r == '.' || r == '_' || r == '-' || r == '+'
+		})
+		parts = append(parts, info)
+
+		for _, part := range parts {
+			if utf8.RuneCountInString(part) >= 3 && strings.Contains(lowered, part) {
+				return part, true
+			}
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,48 @@
+package passwordpolicy
+
+import (
+	"api/src/apperrors"
+	"errors"
+	"testing"
+)
+
+func TestCheckPersonalInfo(t *testing.T) {
+	policy := Policy{MinLength: 10, MaxLength: 128}
+
+	tests := []struct {
+		name     string
+		password string
+		personal []string
+		rejected bool
+	}{
+		{"email domain is allowed", "comfortable-horse-battery", []string{"john@example.com"}, false},
+		{"email provider is allowed", "gmail-horse-battery-staple", []string{"john@gmail.com"}, false},
+		{"email local part is refused", "horse-john-battery", []string{"john@example.com"}, true},
+		{"email local part words are refused", "horse-smith-battery", []string{"john.smith@example.com"}, true},
+		{"name is refused", "battery-johnny-staple", []string{"Johnny Doe"}, true},
+		{"short parts are ignored", "horse-battery-staple", []string{"Al Bo", "al@example.com"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := policy.Check(test.password, test.personal...)
+			if got := hasViolation(err, "personal_info"); got != test.rejected {
+				t.Errorf("personal_info violation = %v, want %v (error: %v)", got, test.rejected, err)
+			}
+		})
+	}
+}
+
+func hasViolation(err error, code string) bool {
+	var appErr *apperrors.Error
+	if !errors.As(err, &appErr) {
+		return false
+	}
+
+	for _, field := range appErr.Fields {
+		if field.Code == code {
+			return true
+		}
+	}
+	return false
+}
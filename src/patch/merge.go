@@ -0,0 +1,42 @@
+package patch
+
+import "encoding/json"
+
+// MergePatch applies an RFC 7396 JSON Merge Patch document to original.
+func MergePatch(original, patch []byte) ([]byte, error) {
+	var target interface{}
+	if len(original) > 0 {
+		if err := json.Unmarshal(original, &target); err != nil {
+			return nil, err
+		}
+	}
+
+	var patchDocument interface{}
+	if err := json.Unmarshal(patch, &patchDocument); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(mergeValue(target, patchDocument))
+}
+
+func mergeValue(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergeValue(targetObject[key], value)
+	}
+
+	return targetObject
+}
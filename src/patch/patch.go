@@ -0,0 +1,104 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"reflect"
+	"sort"
+)
+
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+var ErrUnsupportedContentType = errors.New("unsupported patch content type, use application/merge-patch+json or application/json-patch+json")
+
+// Apply patches the fields of original listed in editable with the document in
+// body, decodes the result into target and returns the editable fields whose
+// value changed. Patching any other field is rejected.
+func Apply(contentType string, original interface{}, editable []string, body []byte, target interface{}) ([]string, error) {
+	mediaType := MergePatchContentType
+	if contentType != "" {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(contentType); err != nil {
+			return nil, ErrUnsupportedContentType
+		}
+	}
+
+	originalDocument, err := editableDocument(original, editable)
+	if err != nil {
+		return nil, err
+	}
+
+	var patched []byte
+	switch mediaType {
+	case MergePatchContentType, "application/json":
+		patched, err = MergePatch(originalDocument, body)
+	case JSONPatchContentType:
+		patched, err = JSONPatch(originalDocument, body)
+	default:
+		return nil, ErrUnsupportedContentType
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var before, after map[string]interface{}
+	if err = json.Unmarshal(originalDocument, &before); err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(patched, &after); err != nil {
+		return nil, errors.New("the patched document must be an object")
+	}
+
+	allowed := make(map[string]bool, len(editable))
+	for _, field := range editable {
+		allowed[field] = true
+	}
+
+	var changed []string
+	for field, value := range after {
+		if !allowed[field] {
+			return nil, fmt.Errorf("field %q can't be patched", field)
+		}
+		if !reflect.DeepEqual(before[field], value) {
+			changed = append(changed, field)
+		}
+	}
+	for field := range before {
+		if _, ok := after[field]; !ok {
+			changed = append(changed, field)
+		}
+	}
+	sort.Strings(changed)
+
+	if err = json.Unmarshal(patched, target); err != nil {
+		return nil, err
+	}
+
+	return changed, nil
+}
+
+func editableDocument(original interface{}, editable []string) ([]byte, error) {
+	encoded, err := json.Marshal(original)
+	if err != nil {
+		return nil, err
+	}
+
+	var document map[string]interface{}
+	if err = json.Unmarshal(encoded, &document); err != nil {
+		return nil, err
+	}
+
+	filtered := make(map[string]interface{}, len(editable))
+	for _, field := range editable {
+		if value, ok := document[field]; ok {
+			filtered[field] = value
+		}
+	}
+
+	return json.Marshal(filtered)
+}
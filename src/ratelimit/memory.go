@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the memory store forgets idle keys.
+const sweepInterval = time.Minute
+
+// MemoryStore keeps the state in the process, so each API instance limits
+// on its own.
+type MemoryStore struct {
+	mutex     sync.Mutex
+	buckets   map[string]bucket
+	windows   map[string]window
+	lifetimes map[string]time.Time
+	swept     time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:   map[string]bucket{},
+		windows:   map[string]window{},
+		lifetimes: map[string]time.Time{},
+	}
+}
+
+func (store *MemoryStore) TokenBucket(key string, limit int, window time.Duration, now time.Time) (Result, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.sweep(now)
+
+	state, result := takeToken(store.buckets[key], limit, window, now)
+	store.buckets[key] = state
+	store.lifetimes[key] = now.Add(window)
+
+	return result, nil
+}
+
+func (store *MemoryStore) SlidingWindow(key string, limit int, length time.Duration, now time.Time) (Result, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.sweep(now)
+
+	state, result := countRequest(store.windows[key], limit, length, now)
+	store.windows[key] = state
+	store.lifetimes[key] = state.start.Add(2 * length)
+
+	return result, nil
+}
+
+// sweep drops the keys whose state no longer matters: a full bucket, or
+// windows that have both slid out.
+func (store *MemoryStore) sweep(now time.Time) {
+	if now.Sub(store.swept) < sweepInterval {
+		return
+	}
+	store.swept = now
+
+	for key, expiry := range store.lifetimes {
+		if now.After(expiry) {
+			delete(store.buckets, key)
+			delete(store.windows, key)
+			delete(store.lifetimes, key)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Package ratelimit decides whether a client may make another request, with
+// a token bucket or a sliding window, in a store shared by the API instances.
+package ratelimit
+
+import (
+	"api/src/config"
+	"fmt"
+	"math"
+	"time"
+)
+
+type Algorithm string
+
+const (
+	// TokenBucket allows bursts of up to Limit requests, refilled evenly over
+	// Window.
+	TokenBucket Algorithm = "token_bucket"
+	// SlidingWindow allows Limit requests in any period of Window, estimated
+	// from the counts of the current and previous fixed windows.
+	SlidingWindow Algorithm = "sliding_window"
+)
+
+// KeyBy tells which client a policy counts requests for.
+type KeyBy string
+
+const (
+	ByIP KeyBy = "ip"
+	// ByUser counts per authenticated user, and per IP for anonymous ones.
+	ByUser KeyBy = "user"
+	// ByToken counts per personal access token, so each one has its own
+	// allowance, per user for session tokens, and per IP for anonymous
+	// clients.
+	ByToken KeyBy = "token"
+)
+
+type Policy struct {
+	// Name keeps the counters of the policy apart from the others.
+	Name      string
+	Algorithm Algorithm
+	Limit     int
+	Window    time.Duration
+	KeyBy     KeyBy
+}
+
+// Result is the outcome of one request against a policy.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is when the client has its full allowance again.
+	Reset time.Duration
+	// RetryAfter is how long a denied client must wait.
+	RetryAfter time.Duration
+}
+
+// Store keeps the state of each key and applies the algorithms atomically.
+type Store interface {
+	TokenBucket(key string, limit int, window time.Duration, now time.Time) (Result, error)
+	SlidingWindow(key string, limit int, window time.Duration, now time.Time) (Result, error)
+}
+
+var current Store = NewMemoryStore()
+
+// Configure picks the store named by config.
+func Configure() error {
+	switch config.RateLimitStore {
+	case "memory":
+		current = NewMemoryStore()
+	case "redis":
+		current = NewRedisStore(config.RedisAddr, config.RedisPassword, config.RedisDB)
+	default:
+		return fmt.Errorf("unknown rate limit store %q", config.RateLimitStore)
+	}
+
+	return nil
+}
+
+// Ping checks that the store can be reached, for stores over the network.
+func Ping() error {
+	if pinger, ok := current.(interface{ Ping() error }); ok {
+		return pinger.Ping()
+	}
+
+	return nil
+}
+
+// Allow counts a request of the client identified by key against policy.
+func Allow(policy Policy, key string) (Result, error) {
+	key = "ratelimit:" + policy.Name + ":" + key
+
+	switch policy.Algorithm {
+	case TokenBucket:
+		return current.TokenBucket(key, policy.Limit, policy.Window, time.Now())
+	case SlidingWindow:
+		return current.SlidingWindow(key, policy.Limit, policy.Window, time.Now())
+	}
+
+	return Result{}, fmt.Errorf("unknown rate limit algorithm %q", policy.Algorithm)
+}
+
+// bucket is the state of a token bucket: the tokens left at updated.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func takeToken(state bucket, limit int, window time.Duration, now time.Time) (bucket, Result) {
+	rate := float64(limit) / window.Seconds()
+
+	if state.updated.IsZero() {
+		state.tokens = float64(limit)
+	} else if elapsed := now.Sub(state.updated).Seconds(); elapsed > 0 {
+		state.tokens = math.Min(float64(limit), state.tokens+elapsed*rate)
+	}
+	state.updated = now
+
+	result := Result{Limit: limit}
+	if state.tokens >= 1 {
+		state.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - state.tokens) / rate)
+	}
+
+	result.Remaining = int(state.tokens)
+	result.Reset = seconds((float64(limit) - state.tokens) / rate)
+	return state, result
+}
+
+// window is the state of a sliding window: the counts of the fixed window
+// starting at start and of the one before it.
+type window struct {
+	start    time.Time
+	current  int
+	previous int
+}
+
+func countRequest(state window, limit int, length time.Duration, now time.Time) (window, Result) {
+	start := now.Truncate(length)
+	switch {
+	case state.start.Equal(start):
+	case state.start.Equal(start.Add(-length)):
+		state = window{start: start, previous: state.current}
+	default:
+		state = window{start: start}
+	}
+
+	elapsed := now.Sub(start).Seconds() / length.Seconds()
+	estimated := float64(state.previous)*(1-elapsed) + float64(state.current)
+
+	result := Result{Limit: limit, Reset: start.Add(length).Sub(now)}
+	if estimated+1 <= float64(limit) {
+		state.current++
+		estimated++
+		result.Allowed = true
+	} else if state.current+1 > limit {
+		result.RetryAfter = result.Reset
+	} else {
+		// Wait until enough of the previous window has slid out.
+		needed := 1 - float64(limit-state.current-1)/float64(state.previous)
+		result.RetryAfter = seconds((needed - elapsed) * length.Seconds())
+	}
+
+	result.Remaining = int(math.Max(0, math.Floor(float64(limit)-estimated)))
+	return state, result
+}
+
+func seconds(value float64) time.Duration {
+	return time.Duration(math.Ceil(value * float64(time.Second)))
+}
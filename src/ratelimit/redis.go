@@ -0,0 +1,130 @@
+package ratelimit
+
+import (
+	"strconv"
+	"time"
+)
+
+// tokenBucketScript mirrors takeToken. Times are in milliseconds.
+const tokenBucketScript = `
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local rate = limit / window
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(state[1])
+local updated = tonumber(state[2])
+
+if tokens == nil then
+  tokens = limit
+elseif now > updated then
+  tokens = math.min(limit, tokens + (now - updated) * rate)
+end
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  retry = math.ceil((1 - tokens) / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', now)
+redis.call('PEXPIRE', KEYS[1], window)
+
+return {allowed, math.floor(tokens), math.ceil((limit - tokens) / rate), retry}
+`
+
+// slidingWindowScript mirrors countRequest. Times are in milliseconds.
+const slidingWindowScript = `
+local limit = tonumber(ARGV[1])
+local length = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local start = now - (now % length)
+
+local state = redis.call('HMGET', KEYS[1], 'start', 'current', 'previous')
+local stored = tonumber(state[1])
+local current = tonumber(state[2]) or 0
+local previous = tonumber(state[3]) or 0
+
+if stored ~= start then
+  if stored == start - length then
+    previous = current
+  else
+    previous = 0
+  end
+  current = 0
+end
+
+local elapsed = (now - start) / length
+local estimated = previous * (1 - elapsed) + current
+local reset = start + length - now
+
+local allowed = 0
+local retry = 0
+if estimated + 1 <= limit then
+  current = current + 1
+  estimated = estimated + 1
+  allowed = 1
+elseif current + 1 > limit then
+  retry = reset
+else
+  local needed = 1 - (limit - current - 1) / previous
+  retry = math.ceil((needed - elapsed) * length)
+end
+
+redis.call('HSET', KEYS[1], 'start', start, 'current', current, 'previous', previous)
+redis.call('PEXPIRE', KEYS[1], 2 * length)
+
+return {allowed, math.max(0, math.floor(limit - estimated)), reset, retry}
+`
+
+// RedisStore keeps the state in Redis, or anything speaking its protocol
+// and running Lua scripts, so every API instance shares the limits.
+type RedisStore struct {
+	client *redisClient
+}
+
+func NewRedisStore(addr, password string, db int) *RedisStore {
+	return &RedisStore{client: newRedisClient(addr, password, db)}
+}
+
+func (store *RedisStore) Ping() error {
+	_, err := store.client.do("PING")
+	return err
+}
+
+func (store *RedisStore) TokenBucket(key string, limit int, window time.Duration, now time.Time) (Result, error) {
+	return store.eval(tokenBucketScript, key, limit, window, now)
+}
+
+func (store *RedisStore) SlidingWindow(key string, limit int, window time.Duration, now time.Time) (Result, error) {
+	return store.eval(slidingWindowScript, key, limit, window, now)
+}
+
+func (store *RedisStore) eval(script, key string, limit int, window time.Duration, now time.Time) (Result, error) {
+	reply, err := store.client.do(
+		"EVAL", script, "1", key,
+		strconv.Itoa(limit),
+		strconv.FormatInt(window.Milliseconds(), 10),
+		strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 10),
+	)
+	if err != nil {
+		return Result{}, err
+	}
+
+	values, err := integers(reply, 4)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{
+		Allowed:    values[0] == 1,
+		Limit:      limit,
+		Remaining:  int(values[1]),
+		Reset:      time.Duration(values[2]) * time.Millisecond,
+		RetryAfter: time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
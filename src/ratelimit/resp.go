@@ -0,0 +1,184 @@
+package ratelimit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	redisTimeout  = 2 * time.Second
+	redisMaxIdle  = 8
+	maxBulkLength = 512 * 1024 * 1024
+)
+
+// redisClient speaks just enough of the Redis protocol (RESP) to run
+// commands, over a small pool of connections.
+type redisClient struct {
+	addr     string
+	password string
+	db       int
+	idle     chan *redisConn
+}
+
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// redisError is an error reply. The connection stays usable after one.
+type redisError string
+
+func (err redisError) Error() string { return "redis: " + string(err) }
+
+func newRedisClient(addr, password string, db int) *redisClient {
+	return &redisClient{addr: addr, password: password, db: db, idle: make(chan *redisConn, redisMaxIdle)}
+}
+
+func (client *redisClient) do(args ...string) (interface{}, error) {
+	conn, err := client.get()
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := conn.do(args...)
+	if _, isReply := err.(redisError); err != nil && !isReply {
+		conn.conn.Close()
+		return nil, err
+	}
+
+	client.put(conn)
+	return reply, err
+}
+
+func (client *redisClient) get() (*redisConn, error) {
+	select {
+	case conn := <-client.idle:
+		return conn, nil
+	default:
+	}
+
+	netConn, err := net.DialTimeout("tcp", client.addr, redisTimeout)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{conn: netConn, reader: bufio.NewReader(netConn)}
+
+	if client.password != "" {
+		if _, err = conn.do("AUTH", client.password); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+
+	if client.db != 0 {
+		if _, err = conn.do("SELECT", strconv.Itoa(client.db)); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+func (client *redisClient) put(conn *redisConn) {
+	select {
+	case client.idle <- conn:
+	default:
+		conn.conn.Close()
+	}
+}
+
+func (conn *redisConn) do(args ...string) (interface{}, error) {
+	conn.conn.SetDeadline(time.Now().Add(redisTimeout))
+
+	writer := bufio.NewWriter(conn.conn)
+	fmt.Fprintf(writer, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(writer, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := writer.Flush(); err != nil {
+		return nil, err
+	}
+
+	return conn.read()
+}
+
+func (conn *redisConn) read() (interface{}, error) {
+	line, err := conn.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("redis: malformed reply")
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, redisError(payload)
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		length, err := strconv.Atoi(payload)
+		if err != nil || length > maxBulkLength {
+			return nil, errors.New("redis: malformed bulk length")
+		}
+		if length < 0 {
+			return nil, nil
+		}
+
+		bulk := make([]byte, length+2)
+		if _, err = io.ReadFull(conn.reader, bulk); err != nil {
+			return nil, err
+		}
+		return string(bulk[:length]), nil
+	case '*':
+		count, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, errors.New("redis: malformed array length")
+		}
+		if count < 0 {
+			return nil, nil
+		}
+
+		items := make([]interface{}, count)
+		for i := range items {
+			// Error replies inside an array are values, not failures.
+			if items[i], err = conn.read(); err != nil {
+				if _, isReply := err.(redisError); !isReply {
+					return nil, err
+				}
+				items[i] = err
+			}
+		}
+		return items, nil
+	}
+
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
+
+// integers reads a reply made of count integers.
+func integers(reply interface{}, count int) ([]int64, error) {
+	items, ok := reply.([]interface{})
+	if !ok || len(items) != count {
+		return nil, fmt.Errorf("redis: expected %d integers, got %v", count, reply)
+	}
+
+	values := make([]int64, count)
+	for i, item := range items {
+		value, ok := item.(int64)
+		if !ok {
+			return nil, fmt.Errorf("redis: expected an integer, got %v", item)
+		}
+		values[i] = value
+	}
+
+	return values, nil
+}
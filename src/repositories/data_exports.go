@@ -0,0 +1,162 @@
+package repositories
+
+import (
+	"api/src/models"
+	"database/sql"
+	"time"
+)
+
+type DataExports struct {
+	db *sql.DB
+}
+
+func NewDataExportRepository(db *sql.DB) *DataExports {
+	return &DataExports{db}
+}
+
+func (repository DataExports) Create(userID uint64, includeHTML bool) (uint64, error) {
+	statement, err := repository.db.Prepare(
+		"INSERT INTO data_exports (user_id, include_html) VALUES (?, ?)",
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer statement.Close()
+
+	result, err := statement.Exec(userID, includeHTML)
+	if err != nil {
+		return 0, err
+	}
+
+	lastInsertID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(lastInsertID), nil
+}
+
+func (repository DataExports) FindById(exportID uint64) (models.DataExport, error) {
+	lines, err := repository.db.Query(`
+		SELECT id, user_id, status, include_html, COALESCE(blob_key, ''), COALESCE(error, ''),
+		created_at, completed_at, expires_at
+		FROM data_exports WHERE id = ?`,
+		exportID,
+	)
+	if err != nil {
+		return models.DataExport{}, err
+	}
+	defer lines.Close()
+
+	var export models.DataExport
+
+	if lines.Next() {
+		if err = lines.Scan(
+			&export.ID,
+			&export.UserID,
+			&export.Status,
+			&export.IncludeHTML,
+			&export.BlobKey,
+			&export.Error,
+			&export.CreatedAt,
+			&export.CompletedAt,
+			&export.ExpiresAt,
+		); err != nil {
+			return models.DataExport{}, err
+		}
+	}
+
+	return export, nil
+}
+
+func (repository DataExports) MarkRunning(exportID uint64) error {
+	statement, err := repository.db.Prepare(
+		"UPDATE data_exports SET status = ? WHERE id = ?",
+	)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	_, err = statement.Exec(models.ExportRunning, exportID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repository DataExports) MarkReady(exportID uint64, blobKey string, retention time.Duration) error {
+	statement, err := repository.db.Prepare(`
+		UPDATE data_exports SET status = ?, blob_key = ?, completed_at = NOW(),
+		expires_at = DATE_ADD(NOW(), INTERVAL ? SECOND) WHERE id = ?`,
+	)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	_, err = statement.Exec(models.ExportReady, blobKey, int64(retention.Seconds()), exportID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repository DataExports) MarkFailed(exportID uint64, reason string) error {
+	statement, err := repository.db.Prepare(
+		"UPDATE data_exports SET status = ?, error = ?, completed_at = NOW() WHERE id = ?",
+	)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	_, err = statement.Exec(models.ExportFailed, reason, exportID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repository DataExports) FindExpired() ([]models.DataExport, error) {
+	lines, err := repository.db.Query(
+		"SELECT id, COALESCE(blob_key, '') FROM data_exports WHERE status = ? AND expires_at <= NOW()",
+		models.ExportReady,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer lines.Close()
+
+	var exports []models.DataExport
+	for lines.Next() {
+		var export models.DataExport
+		if err = lines.Scan(&export.ID, &export.BlobKey); err != nil {
+			return nil, err
+		}
+
+		exports = append(exports, export)
+	}
+
+	return exports, nil
+}
+
+func (repository DataExports) MarkExpired(exportID uint64) error {
+	statement, err := repository.db.Prepare(
+		"UPDATE data_exports SET status = ?, blob_key = NULL WHERE id = ?",
+	)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	_, err = statement.Exec(models.ExportExpired, exportID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package repositories
+
+import "database/sql"
+
+type Identities struct {
+	db *sql.DB
+}
+
+func NewIdentityRepository(db *sql.DB) *Identities {
+	return &Identities{db}
+}
+
+// FindUserID returns the user linked to the external identity, or 0.
+func (repository Identities) FindUserID(provider, subject string) (uint64, error) {
+	var userID uint64
+	err := repository.db.QueryRow(
+		"SELECT user_id FROM user_identities WHERE provider = ? AND subject = ?", provider, subject,
+	).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+func (repository Identities) Create(userID uint64, provider, subject, email string) error {
+	statement, err := repository.db.Prepare(
+		"INSERT INTO user_identities (user_id, provider, subject, email) VALUES (?, ?, ?, ?)",
+	)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	_, err = statement.Exec(userID, provider, subject, email)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
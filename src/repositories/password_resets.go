@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"database/sql"
+	"time"
+)
+
+type PasswordResets struct {
+	db *sql.DB
+}
+
+func NewPasswordResetRepository(db *sql.DB) *PasswordResets {
+	return &PasswordResets{db}
+}
+
+func (repository PasswordResets) Create(userID uint64, tokenHash string, ttl time.Duration) error {
+	statement, err := repository.db.Prepare(
+		"INSERT INTO password_resets (user_id, token_hash, expires_at) VALUES (?, ?, DATE_ADD(NOW(), INTERVAL ? SECOND))",
+	)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	_, err = statement.Exec(userID, tokenHash, int64(ttl.Seconds()))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// FindUser returns the user of a valid reset token without using it up, or 0
+// when the token doesn't exist, has expired or was already used.
+func (repository PasswordResets) FindUser(tokenHash string) (uint64, error) {
+	line, err := repository.db.Query(
+		"SELECT user_id FROM password_resets WHERE token_hash = ? AND used_at IS NULL AND expires_at > NOW()",
+		tokenHash,
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer line.Close()
+
+	var userID uint64
+	if line.Next() {
+		if err = line.Scan(&userID); err != nil {
+			return 0, err
+		}
+	}
+
+	return userID, nil
+}
+
+// Consume marks the reset token as used and returns its user. It returns 0
+// when the token doesn't exist, has expired or was already used.
+func (repository PasswordResets) Consume(tokenHash string) (uint64, error) {
+	statement, err := repository.db.Prepare(
+		"UPDATE password_resets SET used_at = NOW() WHERE token_hash = ? AND used_at IS NULL AND expires_at > NOW()",
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer statement.Close()
+
+	result, err := statement.Exec(tokenHash)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if affected == 0 {
+		return 0, nil
+	}
+
+	line, err := repository.db.Query("SELECT user_id FROM password_resets WHERE token_hash = ?", tokenHash)
+	if err != nil {
+		return 0, err
+	}
+	defer line.Close()
+
+	var userID uint64
+	if line.Next() {
+		if err = line.Scan(&userID); err != nil {
+			return 0, err
+		}
+	}
+
+	return userID, nil
+}
+
+// ExpireByUser uses up every pending reset token of the user. The rows are
+// kept, as the account events export lists the reset requests.
+func (repository PasswordResets) ExpireByUser(userID uint64) error {
+	statement, err := repository.db.Prepare(
+		"UPDATE password_resets SET used_at = NOW() WHERE user_id = ? AND used_at IS NULL",
+	)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	_, err = statement.Exec(userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
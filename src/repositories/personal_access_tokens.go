@@ -0,0 +1,185 @@
+package repositories
+
+import (
+	"api/src/models"
+	"database/sql"
+	"strings"
+)
+
+type PersonalAccessTokens struct {
+	db *sql.DB
+}
+
+func NewPersonalAccessTokenRepository(db *sql.DB) *PersonalAccessTokens {
+	return &PersonalAccessTokens{db}
+}
+
+func (repository PersonalAccessTokens) Create(token models.PersonalAccessToken, tokenHash string) (uint64, error) {
+	statement, err := repository.db.Prepare(`
+		INSERT INTO personal_access_tokens (user_id, name, prefix, token_hash, scopes, expires_at)
+		VALUES (?, ?, ?, ?, ?, IF(? > 0, DATE_ADD(NOW(), INTERVAL ? DAY), NULL))`,
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer statement.Close()
+
+	result, err := statement.Exec(
+		token.UserID, token.Name, token.Prefix, tokenHash, strings.Join(token.Scopes, " "),
+		token.ExpiresInDays, token.ExpiresInDays,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	lastInsertID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(lastInsertID), nil
+}
+
+func (repository PersonalAccessTokens) FindByUser(userID uint64) ([]models.PersonalAccessToken, error) {
+	lines, err := repository.db.Query(`
+		SELECT id, user_id, name, prefix, scopes, last_used_at, expires_at, revoked_at, created_at
+		FROM personal_access_tokens WHERE user_id = ? ORDER BY id DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer lines.Close()
+
+	var tokens []models.PersonalAccessToken
+	for lines.Next() {
+		token, err := scanPersonalAccessToken(lines)
+		if err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	return tokens, nil
+}
+
+func (repository PersonalAccessTokens) FindById(tokenID uint64) (models.PersonalAccessToken, error) {
+	lines, err := repository.db.Query(`
+		SELECT id, user_id, name, prefix, scopes, last_used_at, expires_at, revoked_at, created_at
+		FROM personal_access_tokens WHERE id = ?`,
+		tokenID,
+	)
+	if err != nil {
+		return models.PersonalAccessToken{}, err
+	}
+	defer lines.Close()
+
+	if lines.Next() {
+		return scanPersonalAccessToken(lines)
+	}
+
+	return models.PersonalAccessToken{}, nil
+}
+
+// FindActive returns the token with the given hash if it is neither revoked
+// nor expired and its user still exists, or a token with ID 0.
+func (repository PersonalAccessTokens) FindActive(tokenHash string) (models.PersonalAccessToken, error) {
+	lines, err := repository.db.Query(`
+		SELECT t.id, t.user_id, t.name, t.prefix, t.scopes, t.last_used_at, t.expires_at, t.revoked_at, t.created_at
+		FROM personal_access_tokens t INNER JOIN users u ON u.id = t.user_id
+		WHERE t.token_hash = ? AND t.revoked_at IS NULL
+		AND (t.expires_at IS NULL OR t.expires_at > NOW()) AND u.deleted_at IS NULL`,
+		tokenHash,
+	)
+	if err != nil {
+		return models.PersonalAccessToken{}, err
+	}
+	defer lines.Close()
+
+	if lines.Next() {
+		return scanPersonalAccessToken(lines)
+	}
+
+	return models.PersonalAccessToken{}, nil
+}
+
+// Touch records the token use. It writes at most once a minute per token.
+func (repository PersonalAccessTokens) Touch(tokenID uint64) error {
+	statement, err := repository.db.Prepare(`
+		UPDATE personal_access_tokens SET last_used_at = NOW()
+		WHERE id = ? AND (last_used_at IS NULL OR last_used_at < DATE_SUB(NOW(), INTERVAL 1 MINUTE))`,
+	)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	_, err = statement.Exec(tokenID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repository PersonalAccessTokens) Revoke(userID, tokenID uint64) (bool, error) {
+	statement, err := repository.db.Prepare(
+		"UPDATE personal_access_tokens SET revoked_at = NOW() WHERE id = ? AND user_id = ? AND revoked_at IS NULL",
+	)
+	if err != nil {
+		return false, err
+	}
+	defer statement.Close()
+
+	result, err := statement.Exec(tokenID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (repository PersonalAccessTokens) RevokeAll(userID uint64) error {
+	statement, err := repository.db.Prepare(
+		"UPDATE personal_access_tokens SET revoked_at = NOW() WHERE user_id = ? AND revoked_at IS NULL",
+	)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	_, err = statement.Exec(userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func scanPersonalAccessToken(lines *sql.Rows) (models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	var scopes string
+
+	if err := lines.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		&token.Prefix,
+		&scopes,
+		&token.LastUsedAt,
+		&token.ExpiresAt,
+		&token.RevokedAt,
+		&token.CreatedAt,
+	); err != nil {
+		return models.PersonalAccessToken{}, err
+	}
+
+	token.Scopes = strings.Fields(scopes)
+	return token, nil
+}
@@ -37,9 +37,9 @@ func (repository Posts) Create(post models.Post) (uint64, error) {
 
 func (repository Posts) FindById(postID uint64) (models.Post, error) {
 	lines, err := repository.db.Query(`
-		SELECT p.*, u.nickname FROM 
+		SELECT p.id, p.title, p.content, p.author_id, p.likes, p.version, p.created_at, u.nickname FROM
 		posts p inner join users u
-		on u.id = p.author_id where p.id = ?`,
+		on u.id = p.author_id where p.id = ? and u.deleted_at is null`,
 		postID,
 	)
 	if err != nil {
@@ -56,6 +56,7 @@ func (repository Posts) FindById(postID uint64) (models.Post, error) {
 			&post.Content,
 			&post.AuthorID,
 			&post.Likes,
+			&post.Version,
 			&post.CreatedAt,
 			&post.AuthorNickname,
 		); err != nil {
@@ -68,10 +69,10 @@ func (repository Posts) FindById(postID uint64) (models.Post, error) {
 
 func (repository Posts) Find(userID uint64) ([]models.Post, error) {
 	lines, err := repository.db.Query(`
-		SELECT distinct p.*, u.nickname FROM posts p 
+		SELECT distinct p.id, p.title, p.content, p.author_id, p.likes, p.version, p.created_at, u.nickname FROM posts p
 		inner join users u on u.id = p.author_id
 		inner join followers f on p.author_id = f.user_id
-		where u.id = ? or f.follower_id = ?
+		where (u.id = ? or f.follower_id = ?) and u.deleted_at is null
 		order by 1 desc`,
 		userID, userID,
 	)
@@ -91,6 +92,7 @@ func (repository Posts) Find(userID uint64) ([]models.Post, error) {
 			&post.Content,
 			&post.AuthorID,
 			&post.Likes,
+			&post.Version,
 			&post.CreatedAt,
 			&post.AuthorNickname,
 		); err != nil {
@@ -103,45 +105,66 @@ func (repository Posts) Find(userID uint64) ([]models.Post, error) {
 	return posts, nil
 }
 
-func (repository Posts) Update(postID uint64, post models.Post) error {
+// Update replaces the post if it is still at version. It reports false when
+// the post was changed, or deleted, in the meantime.
+func (repository Posts) Update(postID uint64, post models.Post, version uint64) (bool, error) {
 	statement, err := repository.db.Prepare(
-		"UPDATE posts SET title = ?, content = ? WHERE id = ?",
+		"UPDATE posts SET title = ?, content = ?, version = version + 1 WHERE id = ? AND version = ?",
 	)
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer statement.Close()
 
-	_, err = statement.Exec(post.Title, post.Content, postID)
+	result, err := statement.Exec(post.Title, post.Content, postID, version)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	return nil
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// UpdateFields updates the given fields if the post is still at version.
+func (repository Posts) UpdateFields(postID uint64, post models.Post, fields []string, version uint64) (bool, error) {
+	return updateColumns(repository.db, "posts", "", postID, version, fields, map[string]interface{}{
+		"title":   post.Title,
+		"content": post.Content,
+	})
 }
 
-func (repository Posts) Delete(postID uint64) error {
+// Delete removes the post if it is still at version.
+func (repository Posts) Delete(postID uint64, version uint64) (bool, error) {
 	statement, err := repository.db.Prepare(
-		"DELETE FROM posts WHERE id = ?",
+		"DELETE FROM posts WHERE id = ? AND version = ?",
 	)
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer statement.Close()
 
-	_, err = statement.Exec(postID)
+	result, err := statement.Exec(postID, version)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	return nil
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
 }
 
 func (repository Posts) FindByUser(userID uint64) ([]models.Post, error) {
 	lines, err := repository.db.Query(`
-		SELECT p.*, u.nickname FROM posts p 
+		SELECT p.id, p.title, p.content, p.author_id, p.likes, p.version, p.created_at, u.nickname FROM posts p
 		join users u on u.id = p.author_id
-		where p.author_id = ?`,
+		where p.author_id = ? and u.deleted_at is null`,
 		userID,
 	)
 	if err != nil {
@@ -160,6 +183,7 @@ func (repository Posts) FindByUser(userID uint64) ([]models.Post, error) {
 			&post.Content,
 			&post.AuthorID,
 			&post.Likes,
+			&post.Version,
 			&post.CreatedAt,
 			&post.AuthorNickname,
 		); err != nil {
@@ -171,39 +195,59 @@ func (repository Posts) FindByUser(userID uint64) ([]models.Post, error) {
 
 	return posts, nil
 }
-func (repository Posts) Like(postID uint64) error {
-	statement, err := repository.db.Prepare(
-		"UPDATE posts SET likes = likes + 1 WHERE id = ?",
+
+// Like adds a like to the post. It reports false when the post doesn't
+// exist or its author is pending deletion.
+func (repository Posts) Like(postID uint64) (bool, error) {
+	statement, err := repository.db.Prepare(`
+	UPDATE posts p INNER JOIN users u ON u.id = p.author_id
+	SET p.likes = p.likes + 1, p.version = p.version + 1
+	WHERE p.id = ? AND u.deleted_at IS NULL`,
 	)
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer statement.Close()
 
-	_, err = statement.Exec(postID)
+	result, err := statement.Exec(postID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	return nil
+	return affected > 0, nil
 }
 
-func (repository Posts) Dislike(postID uint64) error {
+// Dislike takes a like back from the post. It reports false when the post
+// doesn't exist or its author is pending deletion.
+func (repository Posts) Dislike(postID uint64) (bool, error) {
 	statement, err := repository.db.Prepare(`
-	UPDATE posts SET likes =
-	CASE 
-		WHEN likes > 0 THEN likes - 1 
-		ELSE 0 AND	
-		WHERE id = ?`,
+	UPDATE posts p INNER JOIN users u ON u.id = p.author_id
+	SET p.likes =
+	CASE
+		WHEN p.likes > 0 THEN p.likes - 1
+		ELSE 0
+	END, p.version = p.version + 1
+	WHERE p.id = ? AND u.deleted_at IS NULL`,
 	)
 	if err != nil {
-		return err
+		return false, err
+	}
+	defer statement.Close()
+
+	result, err := statement.Exec(postID)
+	if err != nil {
+		return false, err
 	}
 
-	_, err = statement.Exec(postID)
+	affected, err := result.RowsAffected()
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	return nil
+	return affected > 0, nil
 }
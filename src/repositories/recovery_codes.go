@@ -0,0 +1,74 @@
+package repositories
+
+import "database/sql"
+
+type RecoveryCodes struct {
+	db *sql.DB
+}
+
+func NewRecoveryCodeRepository(db *sql.DB) *RecoveryCodes {
+	return &RecoveryCodes{db}
+}
+
+// Replace drops the user's recovery codes and stores the new hashes.
+func (repository RecoveryCodes) Replace(userID uint64, codeHashes []string) error {
+	tx, err := repository.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+
+	for _, codeHash := range codeHashes {
+		if _, err = tx.Exec(
+			"INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)", userID, codeHash,
+		); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Use marks an unused code as used and reports whether it was valid.
+func (repository RecoveryCodes) Use(userID uint64, codeHash string) (bool, error) {
+	statement, err := repository.db.Prepare(
+		"UPDATE recovery_codes SET used_at = NOW() WHERE user_id = ? AND code_hash = ? AND used_at IS NULL",
+	)
+	if err != nil {
+		return false, err
+	}
+	defer statement.Close()
+
+	result, err := statement.Exec(userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (repository RecoveryCodes) DeleteByUser(userID uint64) error {
+	statement, err := repository.db.Prepare(
+		"DELETE FROM recovery_codes WHERE user_id = ?",
+	)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	_, err = statement.Exec(userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// expression is a column value computed by MySQL, e.g. from other columns,
+// with the arguments of its placeholders.
+type expression struct {
+	sql       string
+	arguments []interface{}
+}
+
+// updateColumns updates only the given columns of the row identified by id,
+// if the row is still at version, and bumps the version. It reports false
+// when the row was changed in the meantime. Column names must come from a
+// fixed whitelist, never from user input, as must condition, which the row
+// must also meet when it isn't empty, e.g. "deleted_at IS NULL".
+func updateColumns(db *sql.DB, table, condition string, id, version uint64, columns []string, values map[string]interface{}) (bool, error) {
+	if len(columns) == 0 {
+		return true, nil
+	}
+
+	assignments := make([]string, 0, len(columns)+1)
+	arguments := make([]interface{}, 0, len(columns)+2)
+	for _, column := range columns {
+		value, ok := values[column]
+		if !ok {
+			return false, fmt.Errorf("column %q can't be updated", column)
+		}
+		if computed, ok := value.(expression); ok {
+			assignments = append(assignments, column+" = "+computed.sql)
+			arguments = append(arguments, computed.arguments...)
+			continue
+		}
+		assignments = append(assignments, column+" = ?")
+		arguments = append(arguments, value)
+	}
+	assignments = append(assignments, "version = version + 1")
+	arguments = append(arguments, id, version)
+
+	where := "id = ? AND version = ?"
+	if condition != "" {
+		where += " AND " + condition
+	}
+
+	statement, err := db.Prepare(
+		fmt.Sprintf("UPDATE %s SET %s WHERE %s", table, strings.Join(assignments, ", "), where),
+	)
+	if err != nil {
+		return false, err
+	}
+	defer statement.Close()
+
+	result, err := statement.Exec(arguments...)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
@@ -0,0 +1,153 @@
+package repositories
+
+import (
+	"api/src/models"
+	"database/sql"
+)
+
+type Sessions struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) *Sessions {
+	return &Sessions{db}
+}
+
+func (repository Sessions) Create(session models.Session) (uint64, error) {
+	statement, err := repository.db.Prepare(
+		"INSERT INTO sessions (user_id, user_agent, ip, last_seen_at, expires_at) VALUES (?, ?, ?, NOW(), ?)",
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer statement.Close()
+
+	result, err := statement.Exec(session.UserID, session.UserAgent, session.IP, session.ExpiresAt)
+	if err != nil {
+		return 0, err
+	}
+
+	lastInsertID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(lastInsertID), nil
+}
+
+// FindByUser returns the sessions of the user that weren't revoked and
+// haven't expired, the most recently used first.
+func (repository Sessions) FindByUser(userID uint64) ([]models.Session, error) {
+	lines, err := repository.db.Query(`
+		SELECT id, user_id, user_agent, ip, created_at, last_seen_at, expires_at
+		FROM sessions WHERE user_id = ? AND revoked_at IS NULL AND expires_at > NOW() ORDER BY last_seen_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer lines.Close()
+
+	var sessions []models.Session
+	for lines.Next() {
+		var session models.Session
+		if err = lines.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.UserAgent,
+			&session.IP,
+			&session.CreatedAt,
+			&session.LastSeenAt,
+			&session.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+// Active reports whether the session belongs to the user, wasn't revoked and
+// hasn't expired.
+func (repository Sessions) Active(userID, sessionID uint64) (bool, error) {
+	var active bool
+	err := repository.db.QueryRow(
+		"SELECT COUNT(*) > 0 FROM sessions WHERE id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > NOW()",
+		sessionID, userID,
+	).Scan(&active)
+
+	return active, err
+}
+
+// Touch records the session use. It writes at most once a minute per session.
+func (repository Sessions) Touch(sessionID uint64) error {
+	statement, err := repository.db.Prepare(`
+		UPDATE sessions SET last_seen_at = NOW()
+		WHERE id = ? AND last_seen_at < DATE_SUB(NOW(), INTERVAL 1 MINUTE)`,
+	)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	_, err = statement.Exec(sessionID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repository Sessions) Revoke(userID, sessionID uint64) (bool, error) {
+	statement, err := repository.db.Prepare(
+		"UPDATE sessions SET revoked_at = NOW() WHERE id = ? AND user_id = ? AND revoked_at IS NULL",
+	)
+	if err != nil {
+		return false, err
+	}
+	defer statement.Close()
+
+	result, err := statement.Exec(sessionID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// RevokeOthers revokes every session of the user except keep, and returns how
+// many were revoked. A keep of 0 revokes them all.
+func (repository Sessions) RevokeOthers(userID, keep uint64) (int64, error) {
+	statement, err := repository.db.Prepare(
+		"UPDATE sessions SET revoked_at = NOW() WHERE user_id = ? AND id <> ? AND revoked_at IS NULL",
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer statement.Close()
+
+	result, err := statement.Exec(userID, keep)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// DeleteExpired removes the sessions that have expired, revoked or not, and
+// returns how many were removed.
+func (repository Sessions) DeleteExpired() (int64, error) {
+	result, err := repository.db.Exec("DELETE FROM sessions WHERE expires_at < NOW()")
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
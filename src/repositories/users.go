@@ -4,6 +4,7 @@ import (
 	"api/src/models"
 	"database/sql"
 	"fmt"
+	"time"
 )
 
 type Users struct {
@@ -15,7 +16,6 @@ func NewUserRepository(db *sql.DB) *Users {
 }
 
 func (u Users) Create(user models.User) (uint64, error) {
-	fmt.Println("Creating a new user", user)
 	statement, err := u.db.Prepare(
 		"INSERT INTO users (name, nickname, email, password) VALUES (?, ?, ?, ?)",
 	)
@@ -41,7 +41,7 @@ func (repository Users) Search(nameOrNickname string) ([]models.User, error) {
 	nameOrNickname = fmt.Sprintf("%%%s%%", nameOrNickname)
 
 	lines, err := repository.db.Query(
-		"SELECT id, name, nickname, email, password, created_at FROM users WHERE name LIKE ? OR nickname LIKE ?",
+		"SELECT id, name, nickname, email, password, created_at FROM users WHERE (name LIKE ? OR nickname LIKE ?) AND deleted_at IS NULL",
 		nameOrNickname, nameOrNickname,
 	)
 
@@ -75,7 +75,7 @@ func (repository Users) Search(nameOrNickname string) ([]models.User, error) {
 
 func (repository Users) FindById(id uint64) (models.User, error) {
 	lines, err := repository.db.Query(
-		"SELECT id, name, nickname, email, password, created_at FROM users WHERE id = ?",
+		"SELECT id, name, nickname, email, password, verified_at, version, created_at FROM users WHERE id = ? AND deleted_at IS NULL",
 		id,
 	)
 
@@ -93,6 +93,8 @@ func (repository Users) FindById(id uint64) (models.User, error) {
 			&user.Nickname,
 			&user.Email,
 			&user.Password,
+			&user.VerifiedAt,
+			&user.Version,
 			&user.CreatedAt,
 		); err != nil {
 			return models.User{}, err
@@ -101,21 +103,174 @@ func (repository Users) FindById(id uint64) (models.User, error) {
 	return user, nil
 }
 
-func (repository Users) Update(ID uint64, user models.User) error {
+// keepVerification keeps the verification of the email only when it doesn't
+// change. It must be assigned before the email, since MySQL assigns columns
+// from left to right.
+const keepVerification = "IF(email = ?, verified_at, NULL)"
+
+// Update replaces the profile if it is still at version. It reports false
+// when the user was changed, or deleted, in the meantime. A new email is
+// unverified.
+func (repository Users) Update(ID uint64, user models.User, version uint64) (bool, error) {
 	statement, err := repository.db.Prepare(
-		"UPDATE users SET name = ?, nickname = ?, email = ? WHERE id = ?",
+		"UPDATE users SET verified_at = " + keepVerification + ", name = ?, nickname = ?, email = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL",
 	)
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer statement.Close()
 
-	_, err = statement.Exec(user.Name, user.Nickname, user.Email, ID)
+	result, err := statement.Exec(user.Email, user.Name, user.Nickname, user.Email, ID, version)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	return nil
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// UpdateFields updates the given fields if the user is still at version. A
+// new email is unverified.
+func (repository Users) UpdateFields(ID uint64, user models.User, fields []string, version uint64) (bool, error) {
+	columns := fields
+	for _, field := range fields {
+		if field == "email" {
+			columns = append([]string{"verified_at"}, fields...)
+			break
+		}
+	}
+
+	return updateColumns(repository.db, "users", "deleted_at IS NULL", ID, version, columns, map[string]interface{}{
+		"verified_at": expression{sql: keepVerification, arguments: []interface{}{user.Email}},
+		"name":        user.Name,
+		"nickname":    user.Nickname,
+		"email":       user.Email,
+	})
+}
+
+// SoftDelete marks the account as pending deletion and revokes its tokens and
+// sessions, if it is still at version. The row is only removed by Purge once the grace
+// period is over.
+func (repository Users) SoftDelete(ID uint64, version uint64) (bool, error) {
+	statement, err := repository.db.Prepare(
+		"UPDATE users SET deleted_at = NOW(), token_version = token_version + 1, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL",
+	)
+	if err != nil {
+		return false, err
+	}
+	defer statement.Close()
+
+	result, err := statement.Exec(ID, version)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if affected == 0 {
+		return false, nil
+	}
+
+	if _, err = NewSessionRepository(repository.db).RevokeOthers(ID, 0); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Restore cancels a pending deletion if it is still within the grace period.
+func (repository Users) Restore(ID uint64, gracePeriod time.Duration) (bool, error) {
+	statement, err := repository.db.Prepare(
+		"UPDATE users SET deleted_at = NULL WHERE id = ? AND deleted_at > DATE_SUB(NOW(), INTERVAL ? SECOND)",
+	)
+	if err != nil {
+		return false, err
+	}
+	defer statement.Close()
+
+	result, err := statement.Exec(ID, int64(gracePeriod.Seconds()))
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (repository Users) FindExpiredDeletions(gracePeriod time.Duration) ([]models.User, error) {
+	lines, err := repository.db.Query(
+		"SELECT id, deleted_at FROM users WHERE deleted_at <= DATE_SUB(NOW(), INTERVAL ? SECOND)",
+		int64(gracePeriod.Seconds()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer lines.Close()
+
+	var users []models.User
+	for lines.Next() {
+		var user models.User
+		if err := lines.Scan(&user.ID, &user.DeletedAt); err != nil {
+			return nil, err
+		}
+
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+// Purge permanently removes an account whose deletion is pending, along with
+// everything that cascades from it, and reports what was removed.
+func (repository Users) Purge(ID uint64) (models.AccountPurge, error) {
+	tx, err := repository.db.Begin()
+	if err != nil {
+		return models.AccountPurge{}, err
+	}
+	defer tx.Rollback()
+
+	purge := models.AccountPurge{UserID: ID}
+	if err = tx.QueryRow(
+		"SELECT deleted_at FROM users WHERE id = ? AND deleted_at IS NOT NULL FOR UPDATE", ID,
+	).Scan(&purge.DeletedAt); err != nil {
+		return models.AccountPurge{}, err
+	}
+
+	counts := []struct {
+		query string
+		count *uint64
+	}{
+		{"SELECT COUNT(*) FROM posts WHERE author_id = ?", &purge.Posts},
+		{"SELECT COUNT(*) FROM followers WHERE user_id = ?", &purge.Followers},
+		{"SELECT COUNT(*) FROM followers WHERE follower_id = ?", &purge.Following},
+	}
+	for _, c := range counts {
+		if err = tx.QueryRow(c.query, ID).Scan(c.count); err != nil {
+			return models.AccountPurge{}, err
+		}
+	}
+
+	if _, err = tx.Exec("DELETE FROM users WHERE id = ?", ID); err != nil {
+		return models.AccountPurge{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return models.AccountPurge{}, err
+	}
+
+	purge.PurgedAt = time.Now()
+	return purge, nil
 }
 
 func (repository Users) Delete(ID uint64) error {
@@ -137,7 +292,7 @@ func (repository Users) Delete(ID uint64) error {
 
 func (repository Users) FindByEmail(email string) (models.User, error) {
 	line, err := repository.db.Query(
-		"SELECT id, password FROM users WHERE email = ?",
+		"SELECT id, name, email, password, verified_at, token_version, deleted_at, totp_enabled FROM users WHERE email = ?",
 		email,
 	)
 
@@ -149,7 +304,7 @@ func (repository Users) FindByEmail(email string) (models.User, error) {
 	var user models.User
 
 	if line.Next() {
-		if err := line.Scan(&user.ID, &user.Password); err != nil {
+		if err := line.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.VerifiedAt, &user.TokenVersion, &user.DeletedAt, &user.TOTPEnabled); err != nil {
 			return models.User{}, err
 		}
 	}
@@ -194,7 +349,7 @@ func (repository Users) Unfollow(userID uint64, followerID uint64) error {
 func (repository Users) GetFollowers(userID uint64) ([]models.User, error) {
 	lines, err := repository.db.Query(`
 	SELECT u.id, u.name, u.nickname, u.email, u.created_at 
-	FROM users u INNER JOIN followers f ON u.id = f.follower_id WHERE f.user_id = ?
+	FROM users u INNER JOIN followers f ON u.id = f.follower_id WHERE f.user_id = ? AND u.deleted_at IS NULL
 	`, userID,
 	)
 	if err != nil {
@@ -224,7 +379,7 @@ func (repository Users) GetFollowers(userID uint64) ([]models.User, error) {
 func (repository Users) GetFollowing(userID uint64) ([]models.User, error) {
 	lines, err := repository.db.Query(`
 	SELECT u.id, u.name, u.nickname, u.email, u.created_at 
-	FROM users u INNER JOIN followers f ON u.id = f.user_id WHERE f.follower_id = ?
+	FROM users u INNER JOIN followers f ON u.id = f.user_id WHERE f.follower_id = ? AND u.deleted_at IS NULL
 	`, userID,
 	)
 	if err != nil {
@@ -285,3 +440,190 @@ func (repository Users) UpdatePassword(userID uint64, password string) error {
 
 	return nil
 }
+
+func (repository Users) Verify(userID uint64, email string) (bool, error) {
+	statement, err := repository.db.Prepare(
+		"UPDATE users SET verified_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = ? AND email = ? AND verified_at IS NULL",
+	)
+	if err != nil {
+		return false, err
+	}
+	defer statement.Close()
+
+	result, err := statement.Exec(userID, email)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// ResetPassword sets a new password and bumps the token version, which
+// invalidates every token issued before, and revokes every session.
+func (repository Users) ResetPassword(userID uint64, password string) error {
+	statement, err := repository.db.Prepare(
+		"UPDATE users SET password = ?, token_version = token_version + 1 WHERE id = ?",
+	)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	if _, err = statement.Exec(password, userID); err != nil {
+		return err
+	}
+
+	_, err = NewSessionRepository(repository.db).RevokeOthers(userID, 0)
+	return err
+}
+
+func (repository Users) GetTokenVersion(userID uint64) (uint64, error) {
+	var tokenVersion uint64
+	if err := repository.db.QueryRow(
+		"SELECT token_version FROM users WHERE id = ? AND deleted_at IS NULL", userID,
+	).Scan(&tokenVersion); err != nil {
+		return 0, err
+	}
+
+	return tokenVersion, nil
+}
+
+func (repository Users) FindAccountEvents(userID uint64) ([]models.AccountEvent, error) {
+	lines, err := repository.db.Query(`
+		SELECT 'account_created', created_at FROM users WHERE id = ?
+		UNION ALL SELECT 'email_verified', verified_at FROM users WHERE id = ? AND verified_at IS NOT NULL
+		UNION ALL SELECT 'password_reset_requested', created_at FROM password_resets WHERE user_id = ?
+		UNION ALL SELECT 'data_export_requested', created_at FROM data_exports WHERE user_id = ?
+		ORDER BY 2`,
+		userID, userID, userID, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer lines.Close()
+
+	var events []models.AccountEvent
+	for lines.Next() {
+		var event models.AccountEvent
+		if err = lines.Scan(&event.Type, &event.OccurredAt); err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+func (repository Users) IsAdmin(userID uint64) (bool, error) {
+	var isAdmin bool
+	err := repository.db.QueryRow(
+		"SELECT is_admin FROM users WHERE id = ? AND deleted_at IS NULL", userID,
+	).Scan(&isAdmin)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return isAdmin, nil
+}
+
+// SetPendingTOTPSecret stores a secret that only becomes active once confirmed
+// by EnableTOTP.
+func (repository Users) SetPendingTOTPSecret(userID uint64, secret string) error {
+	statement, err := repository.db.Prepare(
+		"UPDATE users SET totp_secret = ?, totp_enabled = false, totp_last_step = 0 WHERE id = ?",
+	)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	_, err = statement.Exec(secret, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repository Users) GetTOTP(userID uint64) (string, bool, error) {
+	var secret sql.NullString
+	var enabled bool
+	err := repository.db.QueryRow(
+		"SELECT totp_secret, totp_enabled FROM users WHERE id = ?", userID,
+	).Scan(&secret, &enabled)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	return secret.String, enabled, nil
+}
+
+func (repository Users) EnableTOTP(userID uint64) error {
+	statement, err := repository.db.Prepare(
+		"UPDATE users SET totp_enabled = true WHERE id = ? AND totp_secret IS NOT NULL",
+	)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	_, err = statement.Exec(userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repository Users) DisableTOTP(userID uint64) error {
+	statement, err := repository.db.Prepare(
+		"UPDATE users SET totp_secret = NULL, totp_enabled = false, totp_last_step = 0 WHERE id = ?",
+	)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	_, err = statement.Exec(userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UseTOTPStep records step as used and reports false if it, or a later one,
+// was already used, so a code can't be replayed.
+func (repository Users) UseTOTPStep(userID uint64, step int64) (bool, error) {
+	statement, err := repository.db.Prepare(
+		"UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?",
+	)
+	if err != nil {
+		return false, err
+	}
+	defer statement.Close()
+
+	result, err := statement.Exec(step, userID, step)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
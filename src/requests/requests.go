@@ -0,0 +1,162 @@
+// Package requests reads request bodies strictly: bounded in size, in the
+// announced format and without anything the handler doesn't expect. Errors
+// say precisely what is wrong, down to the byte offset.
+package requests
+
+import (
+	"api/src/apperrors"
+	"api/src/config"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ErrEmptyBody is returned by DecodeJSON for requests without a body, which
+// handlers with optional bodies can accept.
+var ErrEmptyBody = apperrors.New(apperrors.KindInvalid, "empty_body", "the request body is empty")
+
+type contextKey struct{}
+
+// WithMaxBodySize returns a copy of ctx limiting request bodies to size bytes.
+func WithMaxBodySize(ctx context.Context, size int64) context.Context {
+	return context.WithValue(ctx, contextKey{}, size)
+}
+
+func maxBodySize(ctx context.Context) int64 {
+	if size, ok := ctx.Value(contextKey{}).(int64); ok && size > 0 {
+		return size
+	}
+
+	return int64(config.MaxBodySize)
+}
+
+// ReadBody reads the whole body, refusing bodies over the size limit of the
+// route.
+func ReadBody(r *http.Request) ([]byte, error) {
+	limit := maxBodySize(r.Context())
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.KindInvalid, "unreadable_body", "the request body couldn't be read")
+	}
+
+	if int64(len(body)) > limit {
+		return nil, apperrors.New(apperrors.KindTooLarge, "too_large",
+			fmt.Sprintf("the request body must not exceed %d bytes", limit))
+	}
+
+	return body, nil
+}
+
+// DecodeJSON decodes the JSON body into target. The body must be sent as
+// JSON, hold exactly one document and only fields target knows.
+func DecodeJSON(r *http.Request, target interface{}) error {
+	body, err := ReadBody(r)
+	if err != nil {
+		return err
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		return ErrEmptyBody
+	}
+
+	if !isJSON(r.Header.Get("Content-Type")) {
+		return apperrors.New(apperrors.KindUnsupportedMediaType, "unsupported_media_type",
+			"the request body must be sent as application/json")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+
+	if err = decoder.Decode(target); err != nil {
+		return decodeError(err, decoder, len(body))
+	}
+
+	end := decoder.InputOffset()
+	if _, err = decoder.Token(); err != io.EOF {
+		return apperrors.New(apperrors.KindInvalid, "malformed_json",
+			fmt.Sprintf("unexpected data after the JSON document, which ends at byte offset %d", end))
+	}
+
+	return nil
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func decodeError(err error, decoder *json.Decoder, size int) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		return apperrors.Wrap(err, apperrors.KindInvalid, "malformed_json",
+			fmt.Sprintf("malformed JSON at byte offset %d: %s", syntaxErr.Offset, strings.TrimPrefix(syntaxErr.Error(), "json: ")))
+
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return apperrors.Wrap(err, apperrors.KindInvalid, "malformed_json",
+			fmt.Sprintf("the JSON document ends unexpectedly at byte offset %d", size))
+
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			field = "body"
+		}
+		message := fmt.Sprintf("%s must be %s", field, describe(typeErr.Type))
+
+		return apperrors.Wrap(err, apperrors.KindInvalid, "invalid_field",
+			fmt.Sprintf("%s, at byte offset %d", message, typeErr.Offset),
+		).WithFields(apperrors.FieldError{Field: field, Code: "type", Message: message})
+
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field, unquoteErr := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		if unquoteErr != nil {
+			field = strings.TrimPrefix(err.Error(), "json: unknown field ")
+		}
+		message := fmt.Sprintf("unknown field %q", field)
+
+		return apperrors.Wrap(err, apperrors.KindInvalid, "invalid_field",
+			fmt.Sprintf("%s, at byte offset %d", message, decoder.InputOffset()),
+		).WithFields(apperrors.FieldError{Field: field, Code: "unknown", Message: message})
+	}
+
+	return apperrors.Wrap(err, apperrors.KindInvalid, "malformed_json", strings.TrimPrefix(err.Error(), "json: "))
+}
+
+func describe(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a non-negative integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	case reflect.Ptr:
+		return describe(t.Elem())
+	}
+
+	return "a " + t.String()
+}
@@ -1,27 +1,106 @@
 package responses
 
 import (
+	"api/src/apperrors"
+	"api/src/logger"
+	"api/src/tracing"
+	"bytes"
 	"encoding/json"
-	"log"
 	"net/http"
+	"strconv"
 )
 
-func JSON(w http.ResponseWriter, statusCode int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
+// JSON encodes data before writing anything, so an encoding failure can
+// still be answered with a 500 instead of a truncated body.
+func JSON(w http.ResponseWriter, r *http.Request, statusCode int, data interface{}) {
+	write(w, r, statusCode, "application/json", data)
+}
+
+// Err answers with an RFC 7807 problem describing err. statusCode applies to
+// errors that aren't application errors or translated database errors. The
+// cause is logged with the correlation fields of the request logger, and only
+// sent to clients for their own mistakes.
+func Err(w http.ResponseWriter, r *http.Request, statusCode int, err error) {
+	appErr := apperrors.From(err, statusCode)
+	status := appErr.Kind.Status()
+
+	fields := []interface{}{
+		"status", status,
+		"code", appErr.Code,
+		"error", err,
+	}
+	if status >= http.StatusInternalServerError {
+		logger.FromContext(r.Context()).Error("request failed", fields...)
+	} else {
+		logger.FromContext(r.Context()).Debug("request rejected", fields...)
+	}
+
+	if appErr.Kind == apperrors.KindUnavailable && w.Header().Get("Retry-After") == "" {
+		w.Header().Set("Retry-After", "1")
+	}
+
+	WriteProblem(w, r, Problem{
+		Type:   "/problems/" + appErr.Code,
+		Status: status,
+		Detail: appErr.Message,
+		Code:   appErr.Code,
+		Errors: appErr.Fields,
+	})
+}
+
+// Problem is an RFC 7807 problem details document.
+type Problem struct {
+	Type    string `json:"type"`
+	Title   string `json:"title"`
+	Status  int    `json:"status"`
+	Detail  string `json:"detail,omitempty"`
+	Code    string `json:"code,omitempty"`
+	TraceID string `json:"trace_id,omitempty"`
+	// Errors details each invalid field of the request.
+	Errors []apperrors.FieldError `json:"errors,omitempty"`
+	// Panic and Stack describe a recovered panic, in debug mode only.
+	Panic string   `json:"panic,omitempty"`
+	Stack []string `json:"stack,omitempty"`
+}
 
+// WriteProblem answers with problem as application/problem+json, tagged with
+// the trace of the request.
+func WriteProblem(w http.ResponseWriter, r *http.Request, problem Problem) {
+	if problem.Type == "" {
+		problem.Type = "about:blank"
+	}
+	if problem.Title == "" {
+		problem.Title = http.StatusText(problem.Status)
+	}
+	if spanContext := tracing.SpanContextFromContext(r.Context()); problem.TraceID == "" && spanContext.IsValid() {
+		problem.TraceID = spanContext.TraceID.String()
+	}
+
+	write(w, r, problem.Status, "application/problem+json", problem)
+}
+
+func write(w http.ResponseWriter, r *http.Request, statusCode int, contentType string, data interface{}) {
+	var body bytes.Buffer
 	if data != nil {
-		if err := json.NewEncoder(w).Encode(data); err != nil {
-			log.Fatal(err)
+		if err := json.NewEncoder(&body).Encode(data); err != nil {
+			logger.FromContext(r.Context()).Error("encoding response", "status", statusCode, "error", err)
+
+			statusCode = http.StatusInternalServerError
+			body.Reset()
+			body.WriteString(`{"type":"about:blank","title":"Internal Server Error","status":500}` + "\n")
+			contentType = "application/problem+json"
 		}
 	}
-}
 
-func Err(w http.ResponseWriter, statusCode int, err error) {
-	JSON(w, statusCode, struct {
-		Error string `json:"error"`
-	}{
-		Error: err.Error(),
-	})
+	w.Header().Set("Content-Type", contentType)
+	if data != nil {
+		w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+	}
+	w.WriteHeader(statusCode)
 
+	// A failed write means the client went away; there is no one left to
+	// answer.
+	if _, err := w.Write(body.Bytes()); err != nil {
+		logger.FromContext(r.Context()).Debug("writing response", "status", statusCode, "error", err)
+	}
 }
@@ -6,7 +6,9 @@ import (
 	"github.com/gorilla/mux"
 )
 
-func GenerateRouter() *mux.Router {
+// GenerateRouter registers every route. It fails when a route isn't
+// described for the OpenAPI document.
+func GenerateRouter() (*mux.Router, error) {
 	r := mux.NewRouter()
 	return routes.ConfigRoutes(r)
 }
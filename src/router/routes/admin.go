@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"api/src/controllers"
+	"net/http"
+)
+
+var adminRoutes = []Routes{
+	{
+		URI:                  "/admin/users/{userId}/unlock",
+		Method:               http.MethodPost,
+		Function:             controllers.UnlockUser,
+		AuthenticationNeeded: true,
+		AdminOnly:            true,
+		Summary:              "Lift the login lockout of a user",
+		Tags:                 []string{"admin"},
+		Status:               http.StatusNoContent,
+	},
+	{
+		URI:                  "/admin/ips/{ip}/unlock",
+		Method:               http.MethodPost,
+		Function:             controllers.UnlockIP,
+		AuthenticationNeeded: true,
+		AdminOnly:            true,
+		Summary:              "Lift the login lockout of an IP address",
+		Tags:                 []string{"admin"},
+		Status:               http.StatusNoContent,
+	},
+}
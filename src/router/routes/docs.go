@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"api/src/controllers"
+	"net/http"
+)
+
+var docsRoutes = []Routes{
+	{
+		URI:                  "/openapi.json",
+		Method:               http.MethodGet,
+		Function:             controllers.OpenAPIDocument,
+		AuthenticationNeeded: false,
+		Unversioned:          true,
+		Summary:              "Get the OpenAPI document of the API",
+		Tags:                 []string{"docs"},
+		Response:             map[string]interface{}{},
+		Status:               http.StatusOK,
+	},
+	{
+		URI:                  "/docs",
+		Method:               http.MethodGet,
+		Function:             controllers.APIDocs,
+		AuthenticationNeeded: false,
+		Unversioned:          true,
+		Summary:              "Browse the API documentation",
+		Tags:                 []string{"docs"},
+		Status:               http.StatusOK,
+		ResponseType:         "text/html",
+	},
+}
@@ -0,0 +1,57 @@
+package routes
+
+import (
+	"api/src/controllers"
+	"api/src/models"
+	"api/src/ratelimit"
+	"net/http"
+	"time"
+)
+
+var exportsRoutes = []Routes{
+	{
+		URI:                  "/users/{userId}/export",
+		Method:               http.MethodPost,
+		Function:             controllers.RequestExport,
+		AuthenticationNeeded: true,
+		// Every export builds its archive in the background, so each user
+		// can only have a few going at once.
+		RateLimit: &ratelimit.Policy{
+			Name:      "export",
+			Algorithm: ratelimit.SlidingWindow,
+			Limit:     3,
+			Window:    time.Hour,
+			KeyBy:     ratelimit.ByUser,
+		},
+		Summary:  "Request an export of the data of a user",
+		Tags:     []string{"exports"},
+		Request:  models.DataExport{},
+		Response: models.DataExport{},
+		Status:   http.StatusAccepted,
+	},
+	{
+		URI:                  "/users/{userId}/exports/{exportId}",
+		Method:               http.MethodGet,
+		Function:             controllers.FindExport,
+		AuthenticationNeeded: true,
+		Summary:              "Get the state of an export",
+		Tags:                 []string{"exports"},
+		Response:             models.DataExport{},
+		Status:               http.StatusOK,
+	},
+	{
+		URI:                  "/exports/{exportId}/download",
+		Method:               http.MethodGet,
+		Function:             controllers.DownloadExport,
+		AuthenticationNeeded: false,
+		Unversioned:          true,
+		Summary:              "Download an export archive through a signed link",
+		Tags:                 []string{"exports"},
+		Query: map[string]string{
+			"expires":   "Unix time after which the link is no longer valid.",
+			"signature": "Signature of the link.",
+		},
+		Status:       http.StatusOK,
+		ResponseType: "application/zip",
+	},
+}
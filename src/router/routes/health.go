@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"api/src/controllers"
+	"api/src/health"
+	"api/src/version"
+	"net/http"
+)
+
+var healthRoutes = []Routes{
+	{
+		URI:                  "/healthz",
+		Method:               http.MethodGet,
+		Function:             controllers.Healthz,
+		AuthenticationNeeded: false,
+		Unversioned:          true,
+		Summary:              "Check that the API is alive",
+		Tags:                 []string{"health"},
+		Response:             map[string]string{},
+		Status:               http.StatusOK,
+	},
+	{
+		URI:                  "/readyz",
+		Method:               http.MethodGet,
+		Function:             controllers.Readyz,
+		AuthenticationNeeded: false,
+		Unversioned:          true,
+		Summary:              "Check that the API and its dependencies are ready",
+		Tags:                 []string{"health"},
+		Response:             health.Report{},
+		Status:               http.StatusOK,
+	},
+	{
+		URI:                  "/version",
+		Method:               http.MethodGet,
+		Function:             controllers.Version,
+		AuthenticationNeeded: false,
+		Unversioned:          true,
+		Summary:              "Get the version of the running build",
+		Tags:                 []string{"health"},
+		Response:             version.Info{},
+		Status:               http.StatusOK,
+	},
+}
@@ -2,12 +2,81 @@ package routes
 
 import (
 	"api/src/controllers"
+	"api/src/models"
+	"api/src/ratelimit"
 	"net/http"
+	"time"
 )
 
 var loginRoute = Routes{
 	URI:                  "/login",
 	Method:               http.MethodPost,
 	Function:             controllers.Login,
+	MaxBodySize:          smallBodySize,
 	AuthenticationNeeded: false,
+	RateLimit: &ratelimit.Policy{
+		Name:      "login",
+		Algorithm: ratelimit.TokenBucket,
+		Limit:     10,
+		Window:    time.Minute,
+		KeyBy:     ratelimit.ByIP,
+	},
+	Summary:  "Log in with email and password",
+	Tags:     []string{"auth"},
+	Request:  models.User{},
+	Response: models.AuthenticationData{},
+	Status:   http.StatusOK,
+}
+
+var twoFactorRoutes = []Routes{
+	{
+		URI:                  "/login/2fa",
+		Method:               http.MethodPost,
+		Function:             controllers.LoginTwoFactor,
+		MaxBodySize:          smallBodySize,
+		AuthenticationNeeded: false,
+		RateLimit: &ratelimit.Policy{
+			Name:      "login-2fa",
+			Algorithm: ratelimit.TokenBucket,
+			Limit:     10,
+			Window:    time.Minute,
+			KeyBy:     ratelimit.ByIP,
+		},
+		Summary:  "Complete a login with a second factor",
+		Tags:     []string{"auth"},
+		Request:  models.TwoFactor{},
+		Response: models.AuthenticationData{},
+		Status:   http.StatusOK,
+	},
+	{
+		URI:                  "/users/{userId}/2fa/enroll",
+		Method:               http.MethodPost,
+		Function:             controllers.EnrollTwoFactor,
+		AuthenticationNeeded: true,
+		Summary:              "Start enrolling in two-factor authentication",
+		Tags:                 []string{"two-factor"},
+		Response:             models.TwoFactor{},
+		Status:               http.StatusOK,
+	},
+	{
+		URI:                  "/users/{userId}/2fa/confirm",
+		Method:               http.MethodPost,
+		Function:             controllers.ConfirmTwoFactor,
+		AuthenticationNeeded: true,
+		Summary:              "Confirm the enrollment and get the recovery codes",
+		Tags:                 []string{"two-factor"},
+		Request:              models.TwoFactor{},
+		Response:             models.TwoFactor{},
+		Status:               http.StatusOK,
+	},
+	{
+		URI:                  "/users/{userId}/2fa/disable",
+		Method:               http.MethodPost,
+		Function:             controllers.DisableTwoFactor,
+		AuthenticationNeeded: true,
+		Summary:              "Disable two-factor authentication",
+		Tags:                 []string{"two-factor"},
+		Request:              models.TwoFactor{},
+		Status:               http.StatusNoContent,
+	},
 }
@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"api/src/controllers"
+	"api/src/models"
+	"net/http"
+)
+
+var oidcRoutes = []Routes{
+	{
+		URI:                  "/auth/{provider}/login",
+		Method:               http.MethodGet,
+		Function:             controllers.OIDCLogin,
+		AuthenticationNeeded: false,
+		Unversioned:          true,
+		Summary:              "Start a login with an identity provider",
+		Tags:                 []string{"auth"},
+		Status:               http.StatusFound,
+	},
+	{
+		URI:                  "/auth/{provider}/callback",
+		Method:               http.MethodGet,
+		Function:             controllers.OIDCCallback,
+		AuthenticationNeeded: false,
+		Unversioned:          true,
+		Summary:              "Complete a login with an identity provider",
+		Tags:                 []string{"auth"},
+		Query: map[string]string{
+			"code":  "Authorization code issued by the provider.",
+			"state": "State sent to the provider when the login started.",
+			"error": "Error reported by the provider.",
+		},
+		Response: models.AuthenticationData{},
+		Status:   http.StatusOK,
+	},
+}
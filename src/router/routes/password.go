@@ -0,0 +1,41 @@
+package routes
+
+import (
+	"api/src/controllers"
+	"api/src/models"
+	"api/src/ratelimit"
+	"net/http"
+	"time"
+)
+
+var passwordRoutes = []Routes{
+	{
+		URI:                  "/password/forgot",
+		Method:               http.MethodPost,
+		Function:             controllers.ForgotPassword,
+		MaxBodySize:          smallBodySize,
+		AuthenticationNeeded: false,
+		RateLimit: &ratelimit.Policy{
+			Name:      "forgot-password",
+			Algorithm: ratelimit.SlidingWindow,
+			Limit:     5,
+			Window:    time.Hour,
+			KeyBy:     ratelimit.ByIP,
+		},
+		Summary: "Send a password reset link by email",
+		Tags:    []string{"password"},
+		Request: models.PasswordReset{},
+		Status:  http.StatusAccepted,
+	},
+	{
+		URI:                  "/password/reset",
+		Method:               http.MethodPost,
+		Function:             controllers.ResetPassword,
+		MaxBodySize:          smallBodySize,
+		AuthenticationNeeded: false,
+		Summary:              "Set a new password with a reset token",
+		Tags:                 []string{"password"},
+		Request:              models.PasswordReset{},
+		Status:               http.StatusNoContent,
+	},
+}
@@ -1,57 +1,130 @@
 package routes
 
 import (
+	"api/src/authentication"
 	"api/src/controllers"
+	"api/src/models"
+	"api/src/ratelimit"
 	"net/http"
+	"time"
 )
 
+// likesRateLimit is shared by likes and dislikes, so alternating between them
+// doesn't double the allowance.
+var likesRateLimit = &ratelimit.Policy{
+	Name:      "likes",
+	Algorithm: ratelimit.TokenBucket,
+	Limit:     60,
+	Window:    time.Minute,
+	KeyBy:     ratelimit.ByToken,
+}
+
 var postsRoutes = []Routes{
 	{
 		URI:                  "/posts",
 		Method:               http.MethodPost,
 		Function:             controllers.CreatePost,
+		Idempotent:           true,
 		AuthenticationNeeded: true,
+		Scopes:               []string{authentication.ScopePostsWrite},
+		RateLimit: &ratelimit.Policy{
+			Name:      "create-post",
+			Algorithm: ratelimit.SlidingWindow,
+			Limit:     30,
+			Window:    time.Hour,
+			KeyBy:     ratelimit.ByToken,
+		},
+		Summary:  "Create a post",
+		Tags:     []string{"posts"},
+		Request:  models.Post{},
+		Response: models.Post{},
+		Status:   http.StatusCreated,
 	},
 	{
 		URI:                  "/posts",
 		Method:               http.MethodGet,
 		Function:             controllers.FindAllPosts,
 		AuthenticationNeeded: true,
+		Scopes:               []string{authentication.ScopePostsRead},
+		Summary:              "List the posts of the user and of the users they follow",
+		Tags:                 []string{"posts"},
+		Response:             []models.Post{},
+		Status:               http.StatusOK,
 	},
 	{
 		URI:                  "/posts/{postId}",
 		Method:               http.MethodGet,
 		Function:             controllers.FindPost,
 		AuthenticationNeeded: true,
+		Scopes:               []string{authentication.ScopePostsRead},
+		Summary:              "Get a post",
+		Tags:                 []string{"posts"},
+		Response:             models.Post{},
+		Status:               http.StatusOK,
 	},
 	{
 		URI:                  "/posts/{postId}",
 		Method:               http.MethodPut,
 		Function:             controllers.UpdatePost,
 		AuthenticationNeeded: true,
+		Scopes:               []string{authentication.ScopePostsWrite},
+		Summary:              "Replace a post",
+		Tags:                 []string{"posts"},
+		Request:              models.Post{},
+		Status:               http.StatusNoContent,
+	},
+	{
+		URI:                  "/posts/{postId}",
+		Method:               http.MethodPatch,
+		Function:             controllers.PatchPost,
+		AuthenticationNeeded: true,
+		Scopes:               []string{authentication.ScopePostsWrite},
+		Summary:              "Update some fields of a post",
+		Tags:                 []string{"posts"},
+		Request:              models.Post{},
+		Status:               http.StatusNoContent,
 	},
 	{
 		URI:                  "/posts/{postId}",
 		Method:               http.MethodDelete,
 		Function:             controllers.DeletePost,
 		AuthenticationNeeded: true,
+		Scopes:               []string{authentication.ScopePostsWrite},
+		Summary:              "Delete a post",
+		Tags:                 []string{"posts"},
+		Status:               http.StatusNoContent,
 	},
 	{
 		URI:                  "/users/{userId}/posts",
 		Method:               http.MethodGet,
 		Function:             controllers.FindPostsByUser,
 		AuthenticationNeeded: true,
+		Scopes:               []string{authentication.ScopePostsRead},
+		Summary:              "List the posts of a user",
+		Tags:                 []string{"posts"},
+		Response:             []models.Post{},
+		Status:               http.StatusOK,
 	},
 	{
 		URI:                  "/posts/{postId}/like",
 		Method:               http.MethodPost,
 		Function:             controllers.LikePost,
 		AuthenticationNeeded: true,
+		Scopes:               []string{authentication.ScopePostsWrite},
+		RateLimit:            likesRateLimit,
+		Summary:              "Like a post",
+		Tags:                 []string{"posts"},
+		Status:               http.StatusNoContent,
 	},
 	{
 		URI:                  "/posts/{postId}/dislike",
 		Method:               http.MethodPost,
 		Function:             controllers.DislikePost,
 		AuthenticationNeeded: true,
+		Scopes:               []string{authentication.ScopePostsWrite},
+		RateLimit:            likesRateLimit,
+		Summary:              "Remove a like from a post",
+		Tags:                 []string{"posts"},
+		Status:               http.StatusNoContent,
 	},
 }
@@ -1,34 +1,209 @@
 package routes
 
 import (
+	"api/src/config"
 	"api/src/middlewares"
+	"api/src/openapi"
+	"api/src/ratelimit"
+	"api/src/responses"
+	"api/src/version"
+	"api/src/versioning"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
+// smallBodySize bounds the bodies of the routes open to anyone, which only
+// take a few short fields.
+const smallBodySize = 4 << 10
+
 type Routes struct {
 	URI                  string
 	Method               string
 	Function             func(http.ResponseWriter, *http.Request)
 	AuthenticationNeeded bool
+	AdminOnly            bool
+	Scopes               []string
+	// RateLimit, when set, limits how often each client can call the route.
+	RateLimit *ratelimit.Policy
+	// MaxBodySize, when set, replaces the default limit on request bodies.
+	MaxBodySize int64
+	// Idempotent routes replay their response to retries sent with the same
+	// Idempotency-Key.
+	Idempotent bool
+
+	// Summary, Tags, Query, Request, Response, Status and ResponseType
+	// describe the route in the OpenAPI document. Every route needs at least
+	// a summary, tags and a status, or the server refuses to start.
+	Summary string
+	Tags    []string
+	Query   map[string]string
+	// Request and Response are values of the body types, nil when there is
+	// no body.
+	Request  interface{}
+	Response interface{}
+	Status   int
+	// ResponseType is the media type of the response when it isn't JSON.
+	ResponseType string
+
+	// Versions lists the API versions serving the route under their prefix,
+	// e.g. /v1, nil for every version. The route is also served at its URI,
+	// as a deprecated alias negotiating the version from the Accept header.
+	Versions []int
+	// Unversioned routes are only served at their URI, for paths clients
+	// don't choose, e.g. health checks or links sent by email.
+	Unversioned bool
+	// Deprecation, when set, is announced in every response of the route.
+	Deprecation *versioning.Deprecation
+}
+
+// aliasesDeprecatedAt is when the unversioned aliases were deprecated, as
+// the versioned routes were introduced.
+var aliasesDeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+
+func ConfigRoutes(r *mux.Router) (*mux.Router, error) {
+	routes := allRoutes()
+
+	document, err := buildDocument(routes)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = openapi.Publish(document); err != nil {
+		return nil, err
+	}
+
+	for _, route := range routes {
+		if route.Unversioned {
+			r.HandleFunc(route.URI, wrap(route, route.URI, -1, route.Deprecation)).Methods(route.Method)
+			continue
+		}
+
+		for _, version := range route.versions() {
+			uri := versioning.Prefix(version) + route.URI
+			r.HandleFunc(uri, wrap(route, uri, version, route.Deprecation)).Methods(route.Method)
+		}
+
+		r.HandleFunc(route.URI, wrap(route, route.URI, 0, route.aliasDeprecation())).Methods(route.Method)
+	}
+
+	return r, nil
+}
+
+// wrap chains the middlewares of route served at uri with version, 0 for
+// its unversioned alias and -1 outside versioning.
+func wrap(route Routes, uri string, version int, deprecation *versioning.Deprecation) http.HandlerFunc {
+	handler := route.Function
+
+	if route.Idempotent {
+		handler = middlewares.Idempotent(route.URI, handler)
+	}
+
+	if route.MaxBodySize != 0 {
+		handler = middlewares.MaxBodySize(route.MaxBodySize, handler)
+	}
+
+	if route.RateLimit != nil {
+		handler = middlewares.RateLimit(*route.RateLimit, handler)
+	}
+
+	if route.AdminOnly {
+		handler = middlewares.Authenticate(middlewares.Admin(handler))
+	} else if route.AuthenticationNeeded {
+		handler = middlewares.Authenticate(handler, route.Scopes...)
+	}
+
+	if deprecation != nil {
+		handler = middlewares.Deprecated(*deprecation, handler)
+	}
+
+	if version >= 0 {
+		handler = middlewares.Version(version, handler)
+	}
+
+	handler = middlewares.Logger(middlewares.Metrics(uri, middlewares.Recover(handler)))
+	return middlewares.RequestID(middlewares.Trace(uri, handler))
 }
 
-func ConfigRoutes(r *mux.Router) *mux.Router {
+func (route Routes) versions() []int {
+	if route.Versions == nil {
+		return versioning.Versions
+	}
+
+	return route.Versions
+}
+
+// aliasDeprecation announces the alias of the route, with the deprecation
+// of the route itself when it has one.
+func (route Routes) aliasDeprecation() *versioning.Deprecation {
+	if route.Deprecation != nil {
+		return route.Deprecation
+	}
+
+	return &versioning.Deprecation{At: aliasesDeprecatedAt, Sunset: config.UnversionedSunset}
+}
+
+func allRoutes() []Routes {
 	routes := usersRoutes
 	routes = append(routes, loginRoute)
+	routes = append(routes, twoFactorRoutes...)
+	routes = append(routes, oidcRoutes...)
 	routes = append(routes, postsRoutes...)
+	routes = append(routes, passwordRoutes...)
+	routes = append(routes, exportsRoutes...)
+	routes = append(routes, adminRoutes...)
+	routes = append(routes, tokensRoutes...)
+	routes = append(routes, sessionsRoutes...)
+	routes = append(routes, healthRoutes...)
+	routes = append(routes, docsRoutes...)
 
+	return routes
+}
+
+// Document generates the OpenAPI document of every route.
+func Document() (openapi.Document, error) {
+	return buildDocument(allRoutes())
+}
+
+// buildDocument describes the versioned paths of the routes, leaving out
+// their deprecated aliases, which behave as the default version.
+func buildDocument(routes []Routes) (openapi.Document, error) {
+	var operations []openapi.Operation
 	for _, route := range routes {
+		paths := []string{route.URI}
+		if !route.Unversioned {
+			paths = paths[:0]
+			for _, version := range route.versions() {
+				paths = append(paths, versioning.Prefix(version)+route.URI)
+			}
+		}
 
-		if route.AuthenticationNeeded {
-			r.HandleFunc(route.URI,
-				middlewares.Logger(middlewares.Authenticate(route.Function)),
-			).Methods(route.Method)
-		} else {
-			r.HandleFunc(route.URI, middlewares.Logger(route.Function)).Methods(route.Method)
+		for _, path := range paths {
+			operations = append(operations, openapi.Operation{
+				Method:       route.Method,
+				Path:         path,
+				Summary:      route.Summary,
+				Tags:         route.Tags,
+				Secured:      route.AuthenticationNeeded || route.AdminOnly,
+				Scopes:       route.Scopes,
+				Query:        route.Query,
+				Idempotent:   route.Idempotent,
+				Deprecated:   route.Deprecation != nil,
+				Request:      route.Request,
+				Response:     route.Response,
+				Status:       route.Status,
+				ResponseType: route.ResponseType,
+			})
 		}
 	}
 
-	return r
+	return openapi.Build(openapi.Info{
+		Title:   "Diegobook API",
+		Version: version.Version,
+		Description: "A small social network: users, followers, posts and likes.\n\n" +
+			"Routes are served under /v1 and /v2, which hides the email addresses of other users. " +
+			"The unversioned paths are deprecated aliases serving the version asked for in the Accept header, " +
+			"e.g. `application/vnd.diegobook.v2+json`, or v1.",
+	}, operations, responses.Problem{})
 }
@@ -0,0 +1,65 @@
+package routes
+
+import (
+	"api/src/openapi"
+	"net/http"
+	"testing"
+)
+
+func TestEveryRouteHasASpecification(t *testing.T) {
+	for _, route := range allRoutes() {
+		if route.Summary == "" || len(route.Tags) == 0 || route.Status == 0 {
+			t.Errorf("%s %s needs a summary, tags and a status", route.Method, route.URI)
+		}
+	}
+
+	if _, err := Document(); err != nil {
+		t.Fatalf("Document() failed: %v", err)
+	}
+}
+
+func TestDocumentHasUniqueOperationIDs(t *testing.T) {
+	document, err := Document()
+	if err != nil {
+		t.Fatalf("Document() failed: %v", err)
+	}
+
+	seen := map[string]string{}
+	for path, item := range document["paths"].(map[string]interface{}) {
+		for method, operation := range item.(map[string]interface{}) {
+			id := operation.(map[string]interface{})["operationId"].(string)
+			if other, ok := seen[id]; ok {
+				t.Errorf("operationId %q is used by %s and %s %s", id, other, method, path)
+			}
+			seen[id] = method + " " + path
+		}
+	}
+}
+
+func TestBuildRejectsRoutesWithoutSpecification(t *testing.T) {
+	tests := []struct {
+		name  string
+		route Routes
+	}{
+		{"no summary", Routes{URI: "/things", Method: http.MethodGet, Tags: []string{"things"}, Status: http.StatusOK}},
+		{"no tags", Routes{URI: "/things", Method: http.MethodGet, Summary: "List things", Status: http.StatusOK}},
+		{"no status", Routes{URI: "/things", Method: http.MethodGet, Summary: "List things", Tags: []string{"things"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := buildDocument([]Routes{test.route}); err == nil {
+				t.Error("buildDocument succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestBuildAcceptsSpecifiedOperation(t *testing.T) {
+	_, err := openapi.Build(openapi.Info{Title: "test"}, []openapi.Operation{
+		{Method: http.MethodGet, Path: "/things", Summary: "List things", Tags: []string{"things"}, Status: http.StatusOK},
+	}, struct{}{})
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+package routes
+
+import (
+	"api/src/controllers"
+	"api/src/models"
+	"net/http"
+)
+
+var sessionsRoutes = []Routes{
+	{
+		URI:                  "/users/{userId}/sessions",
+		Method:               http.MethodGet,
+		Function:             controllers.FindSessions,
+		AuthenticationNeeded: true,
+		Summary:              "List the active sessions of a user",
+		Tags:                 []string{"sessions"},
+		Response:             []models.Session{},
+		Status:               http.StatusOK,
+	},
+	{
+		URI:                  "/users/{userId}/sessions",
+		Method:               http.MethodDelete,
+		Function:             controllers.RevokeOtherSessions,
+		AuthenticationNeeded: true,
+		Summary:              "Revoke every session but the current one",
+		Tags:                 []string{"sessions"},
+		Status:               http.StatusNoContent,
+	},
+	{
+		URI:                  "/users/{userId}/sessions/{sessionId}",
+		Method:               http.MethodDelete,
+		Function:             controllers.RevokeSession,
+		AuthenticationNeeded: true,
+		Summary:              "Revoke a session",
+		Tags:                 []string{"sessions"},
+		Status:               http.StatusNoContent,
+	},
+}
@@ -0,0 +1,40 @@
+package routes
+
+import (
+	"api/src/controllers"
+	"api/src/models"
+	"net/http"
+)
+
+var tokensRoutes = []Routes{
+	{
+		URI:                  "/users/{userId}/tokens",
+		Method:               http.MethodPost,
+		Function:             controllers.CreatePersonalAccessToken,
+		AuthenticationNeeded: true,
+		Summary:              "Create a personal access token",
+		Tags:                 []string{"tokens"},
+		Request:              models.PersonalAccessToken{},
+		Response:             models.PersonalAccessToken{},
+		Status:               http.StatusCreated,
+	},
+	{
+		URI:                  "/users/{userId}/tokens",
+		Method:               http.MethodGet,
+		Function:             controllers.FindPersonalAccessTokens,
+		AuthenticationNeeded: true,
+		Summary:              "List the personal access tokens of a user",
+		Tags:                 []string{"tokens"},
+		Response:             []models.PersonalAccessToken{},
+		Status:               http.StatusOK,
+	},
+	{
+		URI:                  "/users/{userId}/tokens/{tokenId}",
+		Method:               http.MethodDelete,
+		Function:             controllers.RevokePersonalAccessToken,
+		AuthenticationNeeded: true,
+		Summary:              "Revoke a personal access token",
+		Tags:                 []string{"tokens"},
+		Status:               http.StatusNoContent,
+	},
+}
@@ -1,8 +1,12 @@
 package routes
 
 import (
+	"api/src/authentication"
 	"api/src/controllers"
+	"api/src/models"
+	"api/src/ratelimit"
 	"net/http"
+	"time"
 )
 
 var usersRoutes = []Routes{
@@ -10,60 +14,154 @@ var usersRoutes = []Routes{
 		URI:                  "/users",
 		Method:               http.MethodPost,
 		Function:             controllers.CreateUser,
+		Idempotent:           true,
+		MaxBodySize:          smallBodySize,
 		AuthenticationNeeded: false,
+		RateLimit: &ratelimit.Policy{
+			Name:      "register",
+			Algorithm: ratelimit.SlidingWindow,
+			Limit:     5,
+			Window:    time.Hour,
+			KeyBy:     ratelimit.ByIP,
+		},
+		Summary:  "Register a user",
+		Tags:     []string{"users"},
+		Request:  models.User{},
+		Response: models.User{},
+		Status:   http.StatusCreated,
+	},
+	{
+		URI:                  "/users/verify",
+		Method:               http.MethodPost,
+		Function:             controllers.VerifyEmail,
+		AuthenticationNeeded: false,
+		Summary:              "Verify an email address with the token sent by email",
+		Tags:                 []string{"users"},
+		Request:              models.Verification{},
+		Status:               http.StatusNoContent,
+	},
+	{
+		URI:                  "/users/verify/resend",
+		Method:               http.MethodPost,
+		Function:             controllers.ResendVerification,
+		MaxBodySize:          smallBodySize,
+		AuthenticationNeeded: false,
+		RateLimit: &ratelimit.Policy{
+			Name:      "resend-verification",
+			Algorithm: ratelimit.SlidingWindow,
+			Limit:     5,
+			Window:    time.Hour,
+			KeyBy:     ratelimit.ByIP,
+		},
+		Summary: "Send the verification email again",
+		Tags:    []string{"users"},
+		Request: models.Verification{},
+		Status:  http.StatusAccepted,
 	},
 	{
 		URI:                  "/users",
 		Method:               http.MethodGet,
 		Function:             controllers.FindAll,
 		AuthenticationNeeded: true,
+		Scopes:               []string{authentication.ScopeUsersRead},
+		Summary:              "Search users by name or nickname",
+		Tags:                 []string{"users"},
+		Query: map[string]string{
+			"user": "Part of the name or nickname to look for.",
+		},
+		Response: []models.User{},
+		Status:   http.StatusOK,
 	},
 	{
 		URI:                  "/users/{userId}",
 		Method:               http.MethodGet,
 		Function:             controllers.FindUser,
 		AuthenticationNeeded: true,
+		Scopes:               []string{authentication.ScopeUsersRead},
+		Summary:              "Get a user",
+		Tags:                 []string{"users"},
+		Response:             models.User{},
+		Status:               http.StatusOK,
 	},
 	{
 		URI:                  "/users/{userId}",
 		Method:               http.MethodPut,
 		Function:             controllers.UpdateUser,
 		AuthenticationNeeded: true,
+		Summary:              "Replace the profile of a user",
+		Tags:                 []string{"users"},
+		Request:              models.User{},
+		Status:               http.StatusNoContent,
+	},
+	{
+		URI:                  "/users/{userId}",
+		Method:               http.MethodPatch,
+		Function:             controllers.PatchUser,
+		AuthenticationNeeded: true,
+		Summary:              "Update some fields of the profile of a user",
+		Tags:                 []string{"users"},
+		Request:              models.User{},
+		Status:               http.StatusNoContent,
 	},
 	{
 		URI:                  "/users/{userId}",
 		Method:               http.MethodDelete,
 		Function:             controllers.DeleteUser,
 		AuthenticationNeeded: true,
+		Summary:              "Delete a user, restorable by logging in during the grace period",
+		Tags:                 []string{"users"},
+		Status:               http.StatusNoContent,
 	},
 	{
 		URI:                  "/users/{userId}/follow",
 		Method:               http.MethodPost,
 		Function:             controllers.FollowUser,
 		AuthenticationNeeded: true,
+		Scopes:               []string{authentication.ScopeFollowsWrite},
+		Summary:              "Follow a user",
+		Tags:                 []string{"followers"},
+		Status:               http.StatusNoContent,
 	},
 	{
 		URI:                  "/users/{userId}/unfollow",
 		Method:               http.MethodPost,
 		Function:             controllers.UnfollowUser,
 		AuthenticationNeeded: true,
+		Scopes:               []string{authentication.ScopeFollowsWrite},
+		Summary:              "Stop following a user",
+		Tags:                 []string{"followers"},
+		Status:               http.StatusNoContent,
 	},
 	{
 		URI:                  "/users/{userId}/followers",
 		Method:               http.MethodGet,
 		Function:             controllers.GetFollowers,
 		AuthenticationNeeded: true,
+		Scopes:               []string{authentication.ScopeFollowsRead},
+		Summary:              "List the followers of a user",
+		Tags:                 []string{"followers"},
+		Response:             []models.User{},
+		Status:               http.StatusOK,
 	},
 	{
 		URI:                  "/users/{userId}/following",
 		Method:               http.MethodGet,
 		Function:             controllers.GetFollowing,
 		AuthenticationNeeded: true,
+		Scopes:               []string{authentication.ScopeFollowsRead},
+		Summary:              "List the users a user follows",
+		Tags:                 []string{"followers"},
+		Response:             []models.User{},
+		Status:               http.StatusOK,
 	},
 	{
 		URI:                  "/users/{userId}/update-password",
 		Method:               http.MethodPost,
 		Function:             controllers.UpdatePassword,
 		AuthenticationNeeded: true,
+		Summary:              "Change the password of a user",
+		Tags:                 []string{"password"},
+		Request:              models.Password{},
+		Status:               http.StatusNoContent,
 	},
 }
@@ -0,0 +1,203 @@
+package security
+
+import (
+	"api/src/config"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrMismatchedPassword = errors.New("the password doesn't match")
+
+// PasswordHasher hashes passwords into self-describing strings that carry the
+// algorithm and its parameters, so hashes made with older settings can still
+// be verified and later upgraded.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Supports reports whether the encoded hash was made by this algorithm.
+	Supports(encoded string) bool
+	Verify(encoded, password string) error
+	// NeedsRehash reports whether the hash uses weaker parameters than the
+	// hasher is configured with.
+	NeedsRehash(encoded string) bool
+}
+
+var (
+	hasher  PasswordHasher = NewArgon2idHasher(64*1024, 3, 2)
+	hashers                = []PasswordHasher{NewArgon2idHasher(64*1024, 3, 2), NewBcryptHasher(bcrypt.DefaultCost)}
+)
+
+// maxArgon2Memory bounds the memory of one argon2id hash, in KiB, to 4 GiB.
+const maxArgon2Memory = 4 << 20
+
+// Configure selects the hasher used for new hashes from config.PasswordHasher.
+// Parameters argon2 or bcrypt would reject, or panic on, fail here, so the
+// server doesn't start rather than fail every login.
+func Configure() error {
+	switch {
+	case config.Argon2Parallelism < 1 || config.Argon2Parallelism > math.MaxUint8:
+		return fmt.Errorf("ARGON2_PARALLELISM must be between 1 and %d, got %d", math.MaxUint8, config.Argon2Parallelism)
+	case config.Argon2Iterations < 1 || int64(config.Argon2Iterations) > math.MaxUint32:
+		return fmt.Errorf("ARGON2_ITERATIONS must be between 1 and %d, got %d", uint32(math.MaxUint32), config.Argon2Iterations)
+	case config.Argon2Memory < 8*config.Argon2Parallelism || config.Argon2Memory > maxArgon2Memory:
+		return fmt.Errorf("ARGON2_MEMORY must be between %d, 8 KiB per lane, and %d KiB, got %d",
+			8*config.Argon2Parallelism, maxArgon2Memory, config.Argon2Memory)
+	case config.BcryptCost < bcrypt.MinCost || config.BcryptCost > bcrypt.MaxCost:
+		return fmt.Errorf("BCRYPT_COST must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, config.BcryptCost)
+	}
+
+	argon2id := NewArgon2idHasher(uint32(config.Argon2Memory), uint32(config.Argon2Iterations), uint8(config.Argon2Parallelism))
+	bcryptHasher := NewBcryptHasher(config.BcryptCost)
+
+	switch config.PasswordHasher {
+	case "argon2id":
+		hasher = argon2id
+	case "bcrypt":
+		hasher = bcryptHasher
+	default:
+		return fmt.Errorf("unknown password hasher %q", config.PasswordHasher)
+	}
+
+	hashers = []PasswordHasher{argon2id, bcryptHasher}
+	return nil
+}
+
+// NeedsRehash reports whether the hash should be replaced by one made with the
+// current hasher and parameters.
+func NeedsRehash(encoded string) bool {
+	return !hasher.Supports(encoded) || hasher.NeedsRehash(encoded)
+}
+
+type Argon2idHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  int
+	KeyLength   uint32
+}
+
+func NewArgon2idHasher(memory, iterations uint32, parallelism uint8) *Argon2idHasher {
+	return &Argon2idHasher{
+		Memory:      memory,
+		Iterations:  iterations,
+		Parallelism: parallelism,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+// Hash returns the PHC string format, e.g.
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>.
+func (hasher *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, hasher.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, hasher.Iterations, hasher.Memory, hasher.Parallelism, hasher.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, hasher.Memory, hasher.Iterations, hasher.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (hasher *Argon2idHasher) Supports(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (hasher *Argon2idHasher) Verify(encoded, password string) error {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return err
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, candidate) != 1 {
+		return ErrMismatchedPassword
+	}
+
+	return nil
+}
+
+func (hasher *Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+
+	return params.Memory < hasher.Memory ||
+		params.Iterations < hasher.Iterations ||
+		params.Parallelism < hasher.Parallelism ||
+		len(salt) < hasher.SaltLength ||
+		uint32(len(key)) < hasher.KeyLength
+}
+
+func decodeArgon2id(encoded string) (Argon2idHasher, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Argon2idHasher{}, nil, nil, errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2idHasher{}, nil, nil, errors.New("unsupported argon2id version")
+	}
+
+	var params Argon2idHasher
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil ||
+		params.Iterations < 1 || params.Parallelism < 1 {
+		return Argon2idHasher{}, nil, nil, errors.New("invalid argon2id parameters")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2idHasher{}, nil, nil, err
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Argon2idHasher{}, nil, nil, err
+	}
+
+	return params, salt, key, nil
+}
+
+type BcryptHasher struct {
+	Cost int
+}
+
+func NewBcryptHasher(cost int) *BcryptHasher {
+	return &BcryptHasher{Cost: cost}
+}
+
+func (hasher *BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), hasher.Cost)
+	return string(hash), err
+}
+
+func (hasher *BcryptHasher) Supports(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (hasher *BcryptHasher) Verify(encoded, password string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return ErrMismatchedPassword
+	}
+
+	return err
+}
+
+func (hasher *BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost < hasher.Cost
+}
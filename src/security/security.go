@@ -1,11 +1,61 @@
 package security
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
 
 func Hash(password string) ([]byte, error) {
-	return bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hash, err := hasher.Hash(password)
+	return []byte(hash), err
 }
 
+// VerifyPassword checks the password against a hash of any supported format.
 func VerifyPassword(hashedPassword, password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+	for _, candidate := range hashers {
+		if candidate.Supports(hashedPassword) {
+			return candidate.Verify(hashedPassword, password)
+		}
+	}
+
+	return errors.New("unsupported password hash")
+}
+
+// GenerateToken returns a random URL-safe token with 256 bits of entropy.
+func GenerateToken() (string, error) {
+	buffer := make([]byte, 32)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buffer), nil
+}
+
+// HashToken returns the hex SHA-256 of a random token. High entropy tokens
+// don't need a slow hash, and a deterministic one lets us look them up.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateRecoveryCode returns a random one-time code formatted for humans,
+// like "k7d2m-q9xa4".
+func GenerateRecoveryCode() (string, error) {
+	buffer := make([]byte, 7)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+
+	code := strings.ToLower(base32.StdEncoding.EncodeToString(buffer))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+// NormalizeRecoveryCode strips the formatting users may type differently.
+func NormalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
 }
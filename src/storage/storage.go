@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"api/src/config"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore keeps binary objects, such as generated archives, by key.
+type BlobStore interface {
+	Put(key string, content io.Reader) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+var current BlobStore
+
+// Configure selects the blob store implementation from config.StorageDriver.
+func Configure() error {
+	switch config.StorageDriver {
+	case "local":
+		current = NewLocalStore(config.StorageDir)
+	default:
+		return fmt.Errorf("unknown storage %q", config.StorageDriver)
+	}
+
+	return nil
+}
+
+// Use replaces the blob store returned by Current.
+func Use(store BlobStore) {
+	current = store
+}
+
+func Current() BlobStore {
+	return current
+}
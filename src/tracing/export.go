@@ -0,0 +1,265 @@
+package tracing
+
+import (
+	"api/src/config"
+	"api/src/logger"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	batchSize     = 512
+	queueSize     = 2048
+	flushInterval = 5 * time.Second
+)
+
+// Exporter sends finished spans somewhere.
+type Exporter interface {
+	Export(ctx context.Context, spans []*Span) error
+}
+
+type pipeline struct {
+	exporter    Exporter
+	sampleRatio float64
+	queue       chan *Span
+	flush       chan chan struct{}
+	done        chan struct{}
+	stopOnce    sync.Once
+}
+
+// current drops every span until Configure sets an exporter. Trace IDs are
+// still generated and propagated.
+var current = &pipeline{}
+
+func (p *pipeline) sampleNew() bool {
+	return p.exporter != nil && (p.sampleRatio >= 1 || rand.Float64() < p.sampleRatio)
+}
+
+// enqueue never blocks a request: spans are dropped when the queue is full.
+func (p *pipeline) enqueue(span *Span) {
+	if p.queue == nil {
+		return
+	}
+
+	select {
+	case p.queue <- span:
+	default:
+	}
+}
+
+func (p *pipeline) run() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := p.exporter.Export(ctx, batch); err != nil {
+			logger.Default().Error("exporting spans", "spans", len(batch), "error", err)
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case span := <-p.queue:
+			batch = append(batch, span)
+			if len(batch) >= batchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case flushed := <-p.flush:
+			for len(p.queue) > 0 {
+				batch = append(batch, <-p.queue)
+			}
+			export()
+			close(flushed)
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// Configure picks the exporter named by config: "otlp", "stdout" or "none".
+func Configure() error {
+	var exporter Exporter
+	switch config.TracingExporter {
+	case "none", "":
+		current = &pipeline{}
+		return nil
+	case "stdout":
+		exporter = &writerExporter{writer: os.Stdout}
+	case "otlp":
+		exporter = &otlpExporter{
+			endpoint: strings.TrimSuffix(config.OTLPEndpoint, "/") + "/v1/traces",
+			client:   &http.Client{Timeout: 10 * time.Second},
+		}
+	default:
+		return fmt.Errorf("unknown tracing exporter %q", config.TracingExporter)
+	}
+
+	p := &pipeline{
+		exporter:    exporter,
+		sampleRatio: config.TracingSampleRatio,
+		queue:       make(chan *Span, queueSize),
+		flush:       make(chan chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go p.run()
+
+	current = p
+	return nil
+}
+
+// Shutdown exports the spans still queued.
+func Shutdown(ctx context.Context) error {
+	p := current
+	if p.queue == nil {
+		return nil
+	}
+
+	flushed := make(chan struct{})
+	select {
+	case p.flush <- flushed:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-flushed:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	p.stopOnce.Do(func() { close(p.done) })
+	return nil
+}
+
+// writerExporter writes one OTLP JSON document per batch.
+type writerExporter struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+func (exporter *writerExporter) Export(ctx context.Context, spans []*Span) error {
+	body, err := json.Marshal(otlpRequest(spans))
+	if err != nil {
+		return err
+	}
+
+	exporter.mu.Lock()
+	defer exporter.mu.Unlock()
+
+	_, err = exporter.writer.Write(append(body, '\n'))
+	return err
+}
+
+// otlpExporter posts spans to an OpenTelemetry collector with OTLP/HTTP, in
+// its JSON encoding.
+type otlpExporter struct {
+	endpoint string
+	client   *http.Client
+}
+
+func (exporter *otlpExporter) Export(ctx context.Context, spans []*Span) error {
+	body, err := json.Marshal(otlpRequest(spans))
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, exporter.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := exporter.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("collector answered %s", response.Status)
+	}
+
+	return nil
+}
+
+// otlpRequest builds an ExportTraceServiceRequest as defined by the OTLP
+// JSON mapping: IDs in hex, timestamps in nanoseconds as strings.
+func otlpRequest(spans []*Span) interface{} {
+	encoded := make([]interface{}, 0, len(spans))
+	for _, span := range spans {
+		span.mu.Lock()
+		item := map[string]interface{}{
+			"traceId":           span.context.TraceID.String(),
+			"spanId":            span.context.SpanID.String(),
+			"name":              span.name,
+			"kind":              int(span.kind),
+			"startTimeUnixNano": strconv.FormatInt(span.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(span.end.UnixNano(), 10),
+			"attributes":        otlpAttributes(span.attributes),
+		}
+		if span.parent.IsValid() {
+			item["parentSpanId"] = span.parent.String()
+		}
+		if span.failed {
+			item["status"] = map[string]interface{}{"code": 2, "message": span.statusMessage}
+		}
+		span.mu.Unlock()
+
+		encoded = append(encoded, item)
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": otlpAttributes([]Attribute{String("service.name", config.ServiceName)}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": "api/src/tracing"},
+				"spans": encoded,
+			}},
+		}},
+	}
+}
+
+func otlpAttributes(attributes []Attribute) []interface{} {
+	encoded := make([]interface{}, 0, len(attributes))
+	for _, attribute := range attributes {
+		var value map[string]interface{}
+		switch v := attribute.Value.(type) {
+		case string:
+			value = map[string]interface{}{"stringValue": v}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+
+		encoded = append(encoded, map[string]interface{}{"key": attribute.Key, "value": value})
+	}
+
+	return encoded
+}
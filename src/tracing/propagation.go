@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+const traceparentHeader = "traceparent"
+
+// TraceIDHeader carries the trace ID in responses, so a client can report it.
+const TraceIDHeader = "X-Trace-ID"
+
+// Extract reads the W3C traceparent header, e.g.
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01". It returns an
+// invalid span context when the header is missing or malformed.
+func Extract(header http.Header) SpanContext {
+	parts := strings.Split(strings.TrimSpace(header.Get(traceparentHeader)), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return SpanContext{}
+	}
+
+	// Version 00 has exactly four fields; later versions may add more.
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}
+	}
+
+	var sc SpanContext
+	if !decodeHex(sc.TraceID[:], parts[1]) || !decodeHex(sc.SpanID[:], parts[2]) {
+		return SpanContext{}
+	}
+
+	var flags [1]byte
+	if !decodeHex(flags[:], parts[3]) {
+		return SpanContext{}
+	}
+	sc.Sampled = flags[0]&1 == 1
+
+	if !sc.IsValid() {
+		return SpanContext{}
+	}
+
+	return sc
+}
+
+// Inject writes the traceparent header for sc, so the next service continues
+// the trace.
+func Inject(header http.Header, sc SpanContext) {
+	if !sc.IsValid() {
+		return
+	}
+
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+
+	header.Set(traceparentHeader, "00-"+sc.TraceID.String()+"-"+sc.SpanID.String()+"-"+flags)
+}
+
+func decodeHex(dst []byte, value string) bool {
+	if len(value) != hex.EncodedLen(len(dst)) || strings.ToLower(value) != value {
+		return false
+	}
+
+	_, err := hex.Decode(dst, []byte(value))
+	return err == nil
+}
@@ -0,0 +1,159 @@
+// Package tracing records spans of work in the W3C Trace Context model and
+// exports them to an OpenTelemetry collector (OTLP over HTTP) or stdout.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+type TraceID [16]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+type SpanID [8]byte
+
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// SpanContext identifies a span across process boundaries.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+	// Remote is set for a span context received from a client.
+	Remote bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+type Kind int
+
+const (
+	KindInternal Kind = iota + 1
+	KindServer
+	KindClient
+)
+
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+type Span struct {
+	mu            sync.Mutex
+	name          string
+	kind          Kind
+	context       SpanContext
+	parent        SpanID
+	start         time.Time
+	end           time.Time
+	attributes    []Attribute
+	failed        bool
+	statusMessage string
+	ended         bool
+}
+
+type contextKey struct{}
+
+// Start begins a span, child of the span in ctx if there is one, and returns
+// a context carrying it.
+func Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+
+	span := &Span{name: name, kind: kind, start: time.Now()}
+	span.context.SpanID = newSpanID()
+
+	if parent.IsValid() {
+		span.context.TraceID = parent.TraceID
+		span.context.Sampled = parent.Sampled
+		span.parent = parent.SpanID
+	} else {
+		span.context.TraceID = newTraceID()
+		span.context.Sampled = current.sampleNew()
+	}
+
+	return context.WithValue(ctx, contextKey{}, span), span
+}
+
+// ContextWithRemote returns a context whose spans continue the trace of a
+// client, as received in its traceparent header.
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+
+	sc.Remote = true
+	return context.WithValue(ctx, contextKey{}, sc)
+}
+
+// SpanContextFromContext returns the current span context, which is invalid
+// outside of a trace.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	switch value := ctx.Value(contextKey{}).(type) {
+	case *Span:
+		return value.context
+	case SpanContext:
+		return value
+	}
+
+	return SpanContext{}
+}
+
+func (span *Span) SpanContext() SpanContext {
+	return span.context
+}
+
+func (span *Span) SetAttributes(attributes ...Attribute) {
+	span.mu.Lock()
+	defer span.mu.Unlock()
+	span.attributes = append(span.attributes, attributes...)
+}
+
+// SetError marks the span as failed.
+func (span *Span) SetError(message string) {
+	span.mu.Lock()
+	defer span.mu.Unlock()
+	span.failed = true
+	span.statusMessage = message
+}
+
+// End finishes the span and hands it to the exporter, if it is sampled.
+func (span *Span) End() {
+	span.mu.Lock()
+	if span.ended {
+		span.mu.Unlock()
+		return
+	}
+	span.ended = true
+	span.end = time.Now()
+	span.mu.Unlock()
+
+	if span.context.Sampled {
+		current.enqueue(span)
+	}
+}
+
+func String(key, value string) Attribute { return Attribute{key, value} }
+
+func Int(key string, value int) Attribute { return Attribute{key, value} }
+
+func newTraceID() TraceID {
+	var id TraceID
+	rand.Read(id[:])
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	rand.Read(id[:])
+	return id
+}
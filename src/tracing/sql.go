@@ -0,0 +1,20 @@
+package tracing
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	sqlStrings    = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'|"(?:[^"\\]|\\.|"")*"`)
+	sqlNumbers    = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	sqlWhitespace = regexp.MustCompile(`\s+`)
+)
+
+// SanitizeSQL replaces the literals of a statement with ?, so spans never
+// carry user data, and collapses its whitespace.
+func SanitizeSQL(query string) string {
+	query = sqlStrings.ReplaceAllString(query, "?")
+	query = sqlNumbers.ReplaceAllString(query, "?")
+	return strings.TrimSpace(sqlWhitespace.ReplaceAllString(query, " "))
+}
@@ -0,0 +1,128 @@
+// Package validation checks request payloads field by field and reports
+// every violation at once, so clients can point at all the bad fields
+// instead of fixing them one round trip at a time.
+package validation
+
+import (
+	"api/src/apperrors"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/badoux/checkmail"
+)
+
+// Rule checks the value of a field. It returns the violated rule and a
+// message naming the field, or an empty rule when the value is valid.
+type Rule func(field, value string) (rule, message string)
+
+// Validator collects the violations of a payload.
+type Validator struct {
+	violations []apperrors.FieldError
+}
+
+func New() *Validator {
+	return &Validator{}
+}
+
+// Field checks value against rules in order. Only the first violated rule is
+// reported, since the others usually follow from it.
+func (v *Validator) Field(field, value string, rules ...Rule) *Validator {
+	for _, check := range rules {
+		if rule, message := check(field, value); rule != "" {
+			v.violations = append(v.violations, apperrors.FieldError{Field: field, Code: rule, Message: message})
+			break
+		}
+	}
+
+	return v
+}
+
+// Check reports a violation of rule when ok is false, for rules that don't
+// fit a string value.
+func (v *Validator) Check(field string, ok bool, rule, message string) *Validator {
+	if !ok {
+		v.violations = append(v.violations, apperrors.FieldError{Field: field, Code: rule, Message: message})
+	}
+
+	return v
+}
+
+// Merge adds the field details of err, as returned by another check, e.g.
+// the password policy. Errors without details are reported on field.
+func (v *Validator) Merge(field string, err error) *Validator {
+	if err == nil {
+		return v
+	}
+
+	var appErr *apperrors.Error
+	if errors.As(err, &appErr) && len(appErr.Fields) > 0 {
+		v.violations = append(v.violations, appErr.Fields...)
+		return v
+	}
+
+	v.violations = append(v.violations, apperrors.FieldError{Field: field, Code: "invalid", Message: err.Error()})
+	return v
+}
+
+// Err returns nil when nothing was violated, or an error listing every
+// violation otherwise.
+func (v *Validator) Err() error {
+	if len(v.violations) == 0 {
+		return nil
+	}
+
+	return apperrors.New(apperrors.KindInvalid, "validation_failed", summary(v.violations)).WithFields(v.violations...)
+}
+
+func summary(violations []apperrors.FieldError) string {
+	if len(violations) == 1 {
+		return violations[0].Message
+	}
+
+	return fmt.Sprintf("%s (and %d more)", violations[0].Message, len(violations)-1)
+}
+
+// Required rejects empty and blank values.
+func Required() Rule {
+	return func(field, value string) (string, string) {
+		if strings.TrimSpace(value) == "" {
+			return "required", field + " is required"
+		}
+		return "", ""
+	}
+}
+
+// MaxLength rejects values with more than max characters, counted as the
+// database counts them.
+func MaxLength(max int) Rule {
+	return func(field, value string) (string, string) {
+		if utf8.RuneCountInString(strings.TrimSpace(value)) > max {
+			return "max_length", fmt.Sprintf("%s must have at most %d characters", field, max)
+		}
+		return "", ""
+	}
+}
+
+// Matches rejects values not matching pattern; description says what is
+// allowed, e.g. "letters, digits and underscores".
+func Matches(pattern *regexp.Regexp, description string) Rule {
+	return func(field, value string) (string, string) {
+		if !pattern.MatchString(strings.TrimSpace(value)) {
+			return "format", fmt.Sprintf("%s can only contain %s", field, description)
+		}
+		return "", ""
+	}
+}
+
+// Email rejects values that aren't email addresses.
+func Email() Rule {
+	return func(field, value string) (string, string) {
+		if err := checkmail.ValidateFormat(strings.TrimSpace(value)); err != nil {
+			return "email", field + " must be a valid email address"
+		}
+		return "", ""
+	}
+}
@@ -0,0 +1,138 @@
+package validation
+
+import (
+	"api/src/apperrors"
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRules(t *testing.T) {
+	nickname := regexp.MustCompile(`^[a-z_]+$`)
+
+	tests := []struct {
+		name  string
+		rule  Rule
+		value string
+		want  string
+	}{
+		{"required accepts a value", Required(), "john", ""},
+		{"required rejects empty", Required(), "", "required"},
+		{"required rejects blank", Required(), " \t ", "required"},
+		{"max length accepts the limit", MaxLength(4), "ação", ""},
+		{"max length ignores surrounding spaces", MaxLength(4), "  john  ", ""},
+		{"max length rejects longer", MaxLength(4), "johnny", "max_length"},
+		{"matches accepts the pattern", Matches(nickname, "letters"), "john_doe", ""},
+		{"matches ignores surrounding spaces", Matches(nickname, "letters"), " john ", ""},
+		{"matches rejects other characters", Matches(nickname, "letters"), "john-doe", "format"},
+		{"email accepts an address", Email(), "john@example.com", ""},
+		{"email ignores surrounding spaces", Email(), " john@example.com ", ""},
+		{"email rejects a missing domain", Email(), "john@", "email"},
+		{"email rejects a plain word", Email(), "john", "email"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, message := test.rule("field", test.value)
+			if rule != test.want {
+				t.Fatalf("rule = %q, want %q", rule, test.want)
+			}
+			if rule != "" && !strings.HasPrefix(message, "field ") {
+				t.Errorf("message %q doesn't name the field", message)
+			}
+		})
+	}
+}
+
+func TestFieldReportsFirstViolatedRule(t *testing.T) {
+	err := New().Field("name", "", Required(), MaxLength(0)).Err()
+
+	want := []apperrors.FieldError{{Field: "name", Code: "required", Message: "name is required"}}
+	if got := fields(t, err); !reflect.DeepEqual(got, want) {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	policy := apperrors.New(apperrors.KindInvalid, "weak_password", "password is too weak").WithFields(
+		apperrors.FieldError{Field: "password", Code: "too_short", Message: "password is too short"},
+		apperrors.FieldError{Field: "password", Code: "common", Message: "password is too common"},
+	)
+
+	tests := []struct {
+		name string
+		err  error
+		want []apperrors.FieldError
+	}{
+		{"nil adds nothing", nil, nil},
+		{"field details are kept", policy, policy.Fields},
+		{
+			"plain errors are reported on the field",
+			errors.New("password is invalid"),
+			[]apperrors.FieldError{{Field: "password", Code: "invalid", Message: "password is invalid"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := New().Merge("password", test.err).Err()
+			if test.want == nil {
+				if err != nil {
+					t.Fatalf("Err() = %v, want nil", err)
+				}
+				return
+			}
+
+			if got := fields(t, err); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("fields = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestErrCollectsEveryViolation(t *testing.T) {
+	err := New().
+		Field("name", "", Required()).
+		Field("nickname", "john", Required()).
+		Field("email", "john", Email()).
+		Check("age", false, "minimum", "age must be at least 18").
+		Check("terms", true, "accepted", "terms must be accepted").
+		Err()
+
+	want := []apperrors.FieldError{
+		{Field: "name", Code: "required", Message: "name is required"},
+		{Field: "email", Code: "email", Message: "email must be a valid email address"},
+		{Field: "age", Code: "minimum", Message: "age must be at least 18"},
+	}
+	if got := fields(t, err); !reflect.DeepEqual(got, want) {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+
+	var appErr *apperrors.Error
+	errors.As(err, &appErr)
+	if appErr.Kind != apperrors.KindInvalid || appErr.Code != "validation_failed" {
+		t.Errorf("kind and code = %v %q, want an invalid validation_failed error", appErr.Kind, appErr.Code)
+	}
+	if appErr.Message != "name is required (and 2 more)" {
+		t.Errorf("message = %q", appErr.Message)
+	}
+}
+
+func TestErrWithoutViolations(t *testing.T) {
+	if err := New().Field("name", "john", Required()).Err(); err != nil {
+		t.Errorf("Err() = %v, want nil", err)
+	}
+}
+
+func fields(t *testing.T, err error) []apperrors.FieldError {
+	t.Helper()
+
+	var appErr *apperrors.Error
+	if !errors.As(err, &appErr) {
+		t.Fatalf("Err() = %v, want an application error", err)
+	}
+
+	return appErr.Fields
+}
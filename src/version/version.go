@@ -0,0 +1,24 @@
+// Package version describes the running build. The values are injected at
+// link time:
+//
+//	go build -ldflags "-X api/src/version.Version=v1.4.0 -X api/src/version.Commit=$(git rev-parse HEAD) -X api/src/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package version
+
+import "runtime"
+
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+func Get() Info {
+	return Info{Version: Version, Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+}
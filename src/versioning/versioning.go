@@ -0,0 +1,140 @@
+// Package versioning tells which version of the API serves a request. The
+// version comes from the path, e.g. /v2/users, or, on the unversioned
+// aliases kept for older clients, from the Accept header, e.g.
+// "application/vnd.diegobook.v2+json" or "application/json; version=2".
+package versioning
+
+import (
+	"api/src/apperrors"
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	V1 = 1
+	// V2 hides the email addresses of other users in user responses.
+	V2 = 2
+
+	// Default serves the requests that don't ask for a version, so clients
+	// written before versioning keep working.
+	Default = V1
+
+	// Header names the version that served the response.
+	Header = "Api-Version"
+)
+
+// Versions lists the versions served, oldest first.
+var Versions = []int{V1, V2}
+
+var ErrUnsupportedVersion = apperrors.New(apperrors.KindNotAcceptable, "unsupported_version",
+	fmt.Sprintf("the requested API version isn't served, the versions are %s", list()))
+
+var vendorType = regexp.MustCompile(`^application/vnd\.diegobook\.v(\d+)\+json$`)
+
+// Name returns the name of version, e.g. "v2".
+func Name(version int) string {
+	return "v" + strconv.Itoa(version)
+}
+
+// Prefix returns the path prefix of version, e.g. "/v2".
+func Prefix(version int) string {
+	return "/" + Name(version)
+}
+
+// Supported reports whether version is served.
+func Supported(version int) bool {
+	for _, served := range Versions {
+		if served == version {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Negotiate returns the version asked for in an Accept header, or Default
+// when it doesn't name one. The first media range naming a version decides.
+func Negotiate(accept string) (int, error) {
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+
+		requested := ""
+		if match := vendorType.FindStringSubmatch(mediaType); match != nil {
+			requested = match[1]
+		} else if mediaType == "application/json" {
+			requested = strings.TrimPrefix(params["version"], "v")
+		}
+
+		if requested == "" {
+			continue
+		}
+
+		version, err := strconv.Atoi(requested)
+		if err != nil || !Supported(version) {
+			return 0, ErrUnsupportedVersion
+		}
+
+		return version, nil
+	}
+
+	return Default, nil
+}
+
+type contextKey struct{}
+
+// WithVersion returns a copy of ctx served with version.
+func WithVersion(ctx context.Context, version int) context.Context {
+	return context.WithValue(ctx, contextKey{}, version)
+}
+
+// FromContext returns the version serving the request, Default for the
+// routes outside versioning.
+func FromContext(ctx context.Context) int {
+	if version, ok := ctx.Value(contextKey{}).(int); ok {
+		return version
+	}
+
+	return Default
+}
+
+// Deprecation announces that a route is going away.
+type Deprecation struct {
+	// At is when the route was deprecated.
+	At time.Time
+	// Sunset, when set, is when the route stops being served.
+	Sunset time.Time
+	// Successor, when set, is the path to use instead.
+	Successor string
+}
+
+// Write sets the Deprecation (RFC 9745), Sunset (RFC 8594) and successor
+// Link headers.
+func (deprecation Deprecation) Write(header http.Header) {
+	header.Set("Deprecation", fmt.Sprintf("@%d", deprecation.At.Unix()))
+
+	if !deprecation.Sunset.IsZero() {
+		header.Set("Sunset", deprecation.Sunset.UTC().Format(http.TimeFormat))
+	}
+
+	if deprecation.Successor != "" {
+		header.Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, deprecation.Successor))
+	}
+}
+
+func list() string {
+	names := make([]string, 0, len(Versions))
+	for _, version := range Versions {
+		names = append(names, Name(version))
+	}
+
+	return strings.Join(names, ", ")
+}